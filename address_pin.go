@@ -0,0 +1,192 @@
+package kmswallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"log/slog"
+	"sync"
+)
+
+var ErrAddressMismatch = errors.New("address mismatch")
+
+const PolicyReasonAddressMismatch = "AddressMismatch"
+
+// AddressPinStore keeps the addresses aliases are expected to resolve to. Aliases are mutable in KMS, so a pinned
+// address detects an alias that was repointed to another key.
+type AddressPinStore interface {
+	// GetPin returns the address pinned for alias; ok is false when alias is not pinned.
+	GetPin(ctx context.Context, alias string) (address common.Address, ok bool, err error)
+	SetPin(ctx context.Context, alias string, address common.Address) error
+}
+
+// MemoryAddressPinStore is an in-memory AddressPinStore.
+type MemoryAddressPinStore struct {
+	mu   sync.RWMutex
+	pins map[string]common.Address
+}
+
+// NewMemoryAddressPinStore returns a store holding pins, a map of aliases to addresses.
+func NewMemoryAddressPinStore(pins map[string]common.Address) *MemoryAddressPinStore {
+	store := &MemoryAddressPinStore{pins: make(map[string]common.Address, len(pins))}
+	for alias, address := range pins {
+		store.pins[alias] = address
+	}
+
+	return store
+}
+
+func (s *MemoryAddressPinStore) GetPin(_ context.Context, alias string) (common.Address, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	address, ok := s.pins[alias]
+	return address, ok, nil
+}
+
+func (s *MemoryAddressPinStore) SetPin(_ context.Context, alias string, address common.Address) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pins[alias] = address
+	return nil
+}
+
+type addressPinOptions struct {
+	autoPin bool
+}
+
+type AddressPinOption func(o *addressPinOptions)
+
+// AutoPin pins aliases without a pin to the address they first resolve to, trusting that first resolution. The
+// store has to persist across restarts, otherwise every restart trusts the aliases again.
+func AutoPin(enabled bool) AddressPinOption {
+	return func(o *addressPinOptions) {
+		o.autoPin = enabled
+	}
+}
+
+// WithAddressPinStore checks every alias resolved for signing against the address pinned in store, and refuses
+// aliases that resolve to a key of another address with ErrAddressMismatch. Aliases that are addresses, like the
+// default alias of CreateWallet, are checked against themselves. Other aliases without a pin are refused with
+// ErrAddressMismatch, unless AutoPin is enabled.
+func WithAddressPinStore(store AddressPinStore, opts ...AddressPinOption) ProviderOption {
+	options := addressPinOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(p *provider) {
+		p.pins = store
+		p.autoPin = options.autoPin
+	}
+}
+
+// GetKeyIdByAliasWithAddress resolves alias and returns ErrAddressMismatch unless the key derives address. With
+// WithAddressPinStore, address also has to match the pin of alias; an alias without a pin is pinned to address when
+// AutoPin is enabled, and is accepted on address alone otherwise.
+func (c *provider) GetKeyIdByAliasWithAddress(ctx context.Context, alias string, address common.Address) (string, error) {
+	keyId, err := c.resolveAlias(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.checkAliasAddress(ctx, alias, keyId, address); err != nil {
+		return "", err
+	}
+
+	if err := c.checkPinnedAddress(ctx, alias, keyId, address, true); err != nil {
+		return "", err
+	}
+
+	return keyId, nil
+}
+
+// checkAliasPin checks keyId, the key alias resolves to, against the pin store.
+func (c *provider) checkAliasPin(ctx context.Context, alias string, keyId string) error {
+	if c.pins == nil {
+		return nil
+	}
+
+	publicKey, err := c.getPublicKey(ctx, keyId)
+	if err != nil {
+		return err
+	}
+
+	return c.checkPinnedAddress(ctx, alias, keyId, crypto.PubkeyToAddress(*publicKey), false)
+}
+
+// checkPinnedAddress checks address, the address of keyId, against the pin of alias. Without a pin, address is pinned
+// when AutoPin is enabled; otherwise alias is refused, unless the caller gave address explicitly.
+func (c *provider) checkPinnedAddress(ctx context.Context, alias string, keyId string, address common.Address, explicit bool) error {
+	if c.pins == nil {
+		return nil
+	}
+
+	if common.IsHexAddress(alias) {
+		return c.compareAliasAddress(ctx, alias, keyId, address, common.HexToAddress(alias))
+	}
+
+	pinned, ok, err := c.pins.GetPin(ctx, alias)
+	if err != nil {
+		return fmt.Errorf("can not get the pinned address of alias: %s, err: %w", alias, err)
+	}
+
+	if ok {
+		return c.compareAliasAddress(ctx, alias, keyId, address, pinned)
+	}
+
+	if !c.autoPin {
+		if explicit {
+			return nil
+		}
+
+		c.metrics.IncPolicyRejection("GetKeyIdByAlias", PolicyReasonAddressMismatch)
+		c.logger.ErrorContext(ctx, "alias is not pinned", slog.String("alias", alias), slog.String("keyId", keyId))
+		return fmt.Errorf("%w: alias %s is not pinned", ErrAddressMismatch, alias)
+	}
+
+	if err := c.pins.SetPin(ctx, alias, address); err != nil {
+		return fmt.Errorf("can not pin the address of alias: %s, err: %w", alias, err)
+	}
+
+	c.logger.InfoContext(ctx, "pinned alias address", slog.String("alias", alias), slog.String("keyId", keyId), slog.String("address", address.Hex()))
+	return nil
+}
+
+func (c *provider) checkAliasAddress(ctx context.Context, alias string, keyId string, expected common.Address) error {
+	publicKey, err := c.getPublicKey(ctx, keyId)
+	if err != nil {
+		return err
+	}
+
+	return c.compareAliasAddress(ctx, alias, keyId, crypto.PubkeyToAddress(*publicKey), expected)
+}
+
+func (c *provider) compareAliasAddress(ctx context.Context, alias string, keyId string, address common.Address, expected common.Address) error {
+	if address != expected {
+		c.metrics.IncPolicyRejection("GetKeyIdByAlias", PolicyReasonAddressMismatch)
+		c.logger.ErrorContext(ctx, "alias resolves to a key of another address", slog.String("alias", alias), slog.String("keyId", keyId),
+			slog.String("address", address.Hex()), slog.String("expected", expected.Hex()))
+		return fmt.Errorf("%w: alias %s resolves to key %s of %s, expected %s", ErrAddressMismatch, alias, keyId, address, expected)
+	}
+
+	return nil
+}
+
+// resolveAlias returns the keyId alias points to, without checking its address.
+func (c *provider) resolveAlias(ctx context.Context, alias string) (string, error) {
+	prefixedAlias := getPrefixedAlias(alias)
+	output, err := c.client.DescribeKey(ctx, &kms.DescribeKeyInput{
+		KeyId: &prefixedAlias,
+	})
+
+	if err != nil {
+		c.logger.WarnContext(ctx, "can not resolve alias", slog.String("alias", alias), slog.Any("err", err))
+		return "", fmt.Errorf("can not get public key from KMS for alias: %s, err: %+v", alias, err)
+	}
+
+	c.logger.DebugContext(ctx, "resolved alias", slog.String("alias", alias), slog.String("keyId", *output.KeyMetadata.KeyId))
+	return *output.KeyMetadata.KeyId, nil
+}
@@ -0,0 +1,161 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAddressPinStore_Should_Refuse_Unpinned_And_Repointed_Aliases(t *testing.T) {
+	// given
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	otherKeyId := client.AddKey(t)
+	client.AddAlias("treasury", keyId)
+	client.AddAlias("payouts", otherKeyId)
+
+	pins := kmswallet.NewMemoryAddressPinStore(map[string]common.Address{"treasury": client.Address(keyId)})
+	metrics := newRecordingMetrics()
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithAddressPinStore(pins), kmswallet.WithMetrics(metrics))
+
+	// when
+	resolvedKeyId, err := provider.GetKeyIdByAlias(context.Background(), "treasury")
+	_, unpinnedErr := provider.SignMessageByAlias(context.Background(), "payouts", []byte("hello"))
+	_, pinned, _ := pins.GetPin(context.Background(), "payouts")
+
+	client.AddAlias("treasury", otherKeyId)
+	_, repointedErr := provider.GetKeyIdByAlias(context.Background(), "treasury")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, keyId, resolvedKeyId)
+	assert.ErrorIs(t, unpinnedErr, kmswallet.ErrAddressMismatch)
+	assert.ErrorContains(t, unpinnedErr, "alias payouts is not pinned")
+	assert.False(t, pinned)
+	assert.ErrorIs(t, repointedErr, kmswallet.ErrAddressMismatch)
+	assert.Equal(t, map[string]int{"GetKeyIdByAlias:AddressMismatch": 2}, metrics.policyRejections)
+}
+
+func TestAddressPinStore_Should_Pin_On_First_Use_With_AutoPin(t *testing.T) {
+	// given
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	otherKeyId := client.AddKey(t)
+	client.AddAlias("treasury", keyId)
+
+	pins := kmswallet.NewMemoryAddressPinStore(nil)
+	metrics := newRecordingMetrics()
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithAddressPinStore(pins, kmswallet.AutoPin(true)), kmswallet.WithMetrics(metrics))
+
+	// when
+	pinnedKeyId, pinErr := provider.GetKeyIdByAlias(context.Background(), "treasury")
+	pinned, ok, _ := pins.GetPin(context.Background(), "treasury")
+
+	client.AddAlias("treasury", otherKeyId)
+	_, signErr := provider.SignMessageByAlias(context.Background(), "treasury", []byte("hello"))
+	_, disableErr := provider.DisableWalletByAlias(context.Background(), "treasury")
+	_, deletionErr := provider.ScheduleWalletDeletionByAlias(context.Background(), "treasury", 7)
+
+	// then
+	assert.NoError(t, pinErr)
+	assert.Equal(t, keyId, pinnedKeyId)
+	assert.True(t, ok)
+	assert.Equal(t, client.Address(keyId), pinned)
+
+	assert.ErrorIs(t, signErr, kmswallet.ErrAddressMismatch)
+	assert.ErrorContains(t, signErr, "alias treasury resolves to key "+otherKeyId+" of "+client.Address(otherKeyId).Hex()+", expected "+client.Address(keyId).Hex())
+	assert.ErrorIs(t, disableErr, kmswallet.ErrAddressMismatch)
+	assert.ErrorIs(t, deletionErr, kmswallet.ErrAddressMismatch)
+	assert.Equal(t, map[string]int{"GetKeyIdByAlias:AddressMismatch": 3}, metrics.policyRejections)
+}
+
+func TestAddressPinStore_Should_Check_Address_Aliases_Against_Themselves(t *testing.T) {
+	// given
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	address := client.Address(keyId)
+	client.AddAlias(address.Hex(), keyId)
+	spoofed := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+	client.AddAlias(spoofed.Hex(), keyId)
+
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithAddressPinStore(kmswallet.NewMemoryAddressPinStore(nil)))
+
+	// when
+	resolvedKeyId, err := provider.GetKeyIdByAlias(context.Background(), address.Hex())
+	_, spoofedErr := provider.GetWalletByAlias(context.Background(), spoofed.Hex())
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, keyId, resolvedKeyId)
+	assert.ErrorIs(t, spoofedErr, kmswallet.ErrAddressMismatch)
+}
+
+func TestGetKeyIdByAliasWithAddress(t *testing.T) {
+	// given
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	client.AddAlias("treasury", keyId)
+	provider := kmswallet.NewProvider(client, nil)
+
+	// when
+	resolvedKeyId, err := provider.GetKeyIdByAliasWithAddress(context.Background(), "treasury", client.Address(keyId))
+	_, mismatchErr := provider.GetKeyIdByAliasWithAddress(context.Background(), "treasury", common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582"))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, keyId, resolvedKeyId)
+	assert.ErrorIs(t, mismatchErr, kmswallet.ErrAddressMismatch)
+}
+
+func TestGetKeyIdByAliasWithAddress_Should_Check_Address_Before_AutoPin(t *testing.T) {
+	// given
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	otherKeyId := client.AddKey(t)
+	client.AddAlias("treasury", otherKeyId)
+
+	pins := kmswallet.NewMemoryAddressPinStore(nil)
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithAddressPinStore(pins, kmswallet.AutoPin(true)))
+
+	// when
+	_, mismatchErr := provider.GetKeyIdByAliasWithAddress(context.Background(), "treasury", client.Address(keyId))
+	_, pinnedAfterMismatch, _ := pins.GetPin(context.Background(), "treasury")
+
+	client.AddAlias("treasury", keyId)
+	resolvedKeyId, err := provider.GetKeyIdByAliasWithAddress(context.Background(), "treasury", client.Address(keyId))
+	pinned, ok, _ := pins.GetPin(context.Background(), "treasury")
+
+	// then
+	assert.ErrorIs(t, mismatchErr, kmswallet.ErrAddressMismatch)
+	assert.False(t, pinnedAfterMismatch)
+	assert.NoError(t, err)
+	assert.Equal(t, keyId, resolvedKeyId)
+	assert.True(t, ok)
+	assert.Equal(t, client.Address(keyId), pinned)
+}
+
+func TestGetKeyIdByAliasWithAddress_Should_Accept_Unpinned_Alias_Without_AutoPin(t *testing.T) {
+	// given
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	otherKeyId := client.AddKey(t)
+	client.AddAlias("payouts", keyId)
+	client.AddAlias("treasury", keyId)
+
+	pins := kmswallet.NewMemoryAddressPinStore(map[string]common.Address{"treasury": client.Address(otherKeyId)})
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithAddressPinStore(pins))
+
+	// when
+	resolvedKeyId, err := provider.GetKeyIdByAliasWithAddress(context.Background(), "payouts", client.Address(keyId))
+	_, pinned, _ := pins.GetPin(context.Background(), "payouts")
+	_, pinMismatchErr := provider.GetKeyIdByAliasWithAddress(context.Background(), "treasury", client.Address(keyId))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, keyId, resolvedKeyId)
+	assert.False(t, pinned)
+	assert.ErrorIs(t, pinMismatchErr, kmswallet.ErrAddressMismatch)
+}
@@ -0,0 +1,145 @@
+package kmswallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/smithy-go"
+	ether_types "github.com/ethereum/go-ethereum/core/types"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchConcurrency = 8
+	maxThrottlingRetries    = 5
+)
+
+var throttlingBackoff = 100 * time.Millisecond
+
+type SignatureResult struct {
+	Signature []byte
+	Err       error
+}
+
+type TransactionResult struct {
+	Transaction *ether_types.Transaction
+	Err         error
+}
+
+// SignHashes signs every hash with at most concurrency parallel KMS Sign calls and returns the results in input order.
+// Signatures have the same [R || S || V] form as SignHash. Throttled calls are retried with an exponential backoff.
+func (c *provider) SignHashes(ctx context.Context, keyId string, hashes [][32]byte, concurrency int) []SignatureResult {
+	results := make([]SignatureResult, len(hashes))
+	c.runBatch(ctx, len(hashes), concurrency, func(i int) {
+		signature, err := c.signDigestWithRetry(ctx, keyId, hashes[i][:])
+		if err == nil {
+			signature[64] += 27
+		}
+
+		results[i] = SignatureResult{Signature: signature, Err: err}
+	}, func(i int, err error) {
+		results[i] = SignatureResult{Err: err}
+	})
+
+	return results
+}
+
+// SignTransactions signs every transaction with the given signer, with at most concurrency parallel KMS Sign calls,
+// and returns the results in input order. Nil transactions, or every transaction when signer is nil, get an error
+// result without a KMS call.
+func (c *provider) SignTransactions(
+	ctx context.Context, keyId string, signer ether_types.Signer, txs []*ether_types.Transaction, concurrency int,
+) []TransactionResult {
+	results := make([]TransactionResult, len(txs))
+	for i, tx := range txs {
+		switch {
+		case signer == nil:
+			results[i] = TransactionResult{Err: fmt.Errorf("transaction %d: signer is required", i)}
+		case tx == nil:
+			results[i] = TransactionResult{Err: fmt.Errorf("transaction %d is nil", i)}
+		}
+	}
+
+	c.runBatch(ctx, len(txs), concurrency, func(i int) {
+		if results[i].Err != nil {
+			return
+		}
+
+		signature, err := c.signDigestWithRetry(ctx, keyId, signer.Hash(txs[i]).Bytes())
+		if err != nil {
+			results[i] = TransactionResult{Err: err}
+			return
+		}
+
+		signedTx, err := txs[i].WithSignature(signer, signature)
+		results[i] = TransactionResult{Transaction: signedTx, Err: err}
+	}, func(i int, err error) {
+		if results[i].Err == nil {
+			results[i] = TransactionResult{Err: err}
+		}
+	})
+
+	return results
+}
+
+// runBatch calls work for every index from a pool of concurrency workers. Indexes that are not started before ctx is
+// done are passed to cancelled instead.
+func (c *provider) runBatch(ctx context.Context, size int, concurrency int, work func(i int), cancelled func(i int, err error)) {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	if concurrency > size {
+		concurrency = size
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					cancelled(i, err)
+					continue
+				}
+
+				work(i)
+			}
+		}()
+	}
+
+	for i := 0; i < size; i++ {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+}
+
+func (c *provider) signDigestWithRetry(ctx context.Context, keyId string, digest []byte) ([]byte, error) {
+	backoff := throttlingBackoff
+	for attempt := 0; ; attempt++ {
+		signature, err := c.signDigest(ctx, keyId, digest)
+		if err == nil || attempt == maxThrottlingRetries || !isThrottlingError(err) {
+			return signature, err
+		}
+
+		c.logger.WarnContext(ctx, "KMS sign throttled, retrying", slog.String("keyId", keyId), slog.Int("attempt", attempt+1), slog.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+func isThrottlingError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && (apiErr.ErrorCode() == "ThrottlingException" || apiErr.ErrorCode() == "LimitExceededException")
+}
@@ -0,0 +1,215 @@
+package kmswallet_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aws/smithy-go"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestHashes(count int) [][32]byte {
+	hashes := make([][32]byte, count)
+	for i := range hashes {
+		hashes[i] = crypto.Keccak256Hash(big.NewInt(int64(i)).Bytes())
+	}
+
+	return hashes
+}
+
+func TestSignHash(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	hash := crypto.Keccak256([]byte("payload"))
+
+	// when
+	signature, err := provider.SignHash(context.Background(), keyId, hash)
+
+	// then
+	assert.NoError(t, err)
+	assert.Contains(t, []byte{27, 28}, signature[64])
+
+	signature[64] -= 27
+	publicKey, err := crypto.SigToPub(hash, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), crypto.PubkeyToAddress(*publicKey))
+}
+
+func TestSignHash_Should_Reject_Invalid_Hash_Length(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+
+	// when
+	_, err := provider.SignHash(context.Background(), keyId, []byte("short"))
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestSignHashes_Should_Return_Results_In_Input_Order_With_Bounded_Parallelism(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	hashes := newTestHashes(40)
+
+	var inFlight, maxInFlight atomic.Int32
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+
+	// when
+	results := provider.SignHashes(context.Background(), keyId, hashes, 4)
+
+	// then
+	assert.Len(t, results, len(hashes))
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(4))
+	for i, result := range results {
+		assert.NoError(t, result.Err)
+		signature := append([]byte(nil), result.Signature...)
+		signature[64] -= 27
+		publicKey, err := crypto.SigToPub(hashes[i][:], signature)
+		assert.NoError(t, err)
+		assert.Equal(t, client.Address(keyId), crypto.PubkeyToAddress(*publicKey))
+	}
+}
+
+func TestSignHashes_Should_Return_Per_Item_Errors(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	hashes := newTestHashes(5)
+	client.SetSignHook(func(ctx context.Context, digest []byte) error {
+		if bytes.Equal(digest, hashes[2][:]) {
+			return errors.New("key is disabled")
+		}
+
+		return nil
+	})
+
+	// when
+	results := provider.SignHashes(context.Background(), keyId, hashes, 2)
+
+	// then
+	for i, result := range results {
+		if i == 2 {
+			assert.EqualError(t, result.Err, "key is disabled")
+			assert.Nil(t, result.Signature)
+		} else {
+			assert.NoError(t, result.Err)
+			assert.Len(t, result.Signature, 65)
+		}
+	}
+}
+
+func TestSignHashes_Should_Retry_Throttled_Calls(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	var throttled atomic.Bool
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		if throttled.CompareAndSwap(false, true) {
+			return &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+		}
+
+		return nil
+	})
+
+	// when
+	results := provider.SignHashes(context.Background(), keyId, newTestHashes(3), 1)
+
+	// then
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+	assert.Equal(t, 4, client.SignCalls())
+}
+
+func TestSignHashes_Should_Fail_Remaining_Items_When_Context_Is_Cancelled(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		cancel()
+		return nil
+	})
+
+	// when
+	results := provider.SignHashes(ctx, keyId, newTestHashes(5), 1)
+
+	// then
+	assert.NoError(t, results[0].Err)
+	for _, result := range results[1:] {
+		assert.ErrorIs(t, result.Err, context.Canceled)
+	}
+}
+
+func TestSignTransactions(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	txs := make([]*types.Transaction, 10)
+	for i := range txs {
+		txs[i] = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			Nonce:     uint64(i),
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(100),
+			Gas:       21000,
+			To:        newTestTransaction().To(),
+			Value:     big.NewInt(1),
+		})
+	}
+
+	// when
+	results := provider.SignTransactions(context.Background(), keyId, signer, txs, 3)
+
+	// then
+	for i, result := range results {
+		assert.NoError(t, result.Err)
+		assert.Equal(t, uint64(i), result.Transaction.Nonce())
+		sender, err := types.Sender(signer, result.Transaction)
+		assert.NoError(t, err)
+		assert.Equal(t, client.Address(keyId), sender)
+	}
+}
+
+func TestSignTransactions_Should_Reject_Nil_Transactions_And_Signer(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	txs := []*types.Transaction{newTestTransaction(), nil, newTestTransaction()}
+
+	// when
+	results := provider.SignTransactions(context.Background(), keyId, signer, txs, 2)
+	withoutSigner := provider.SignTransactions(context.Background(), keyId, nil, txs[:1], 2)
+
+	// then
+	assert.NoError(t, results[0].Err)
+	assert.EqualError(t, results[1].Err, "transaction 1 is nil")
+	assert.Nil(t, results[1].Transaction)
+	assert.NoError(t, results[2].Err)
+	assert.EqualError(t, withoutSigner[0].Err, "transaction 0: signer is required")
+	assert.Equal(t, 2, client.SignCalls())
+}
@@ -0,0 +1,88 @@
+// Command kms-rpc-proxy serves a JSON-RPC endpoint in front of an Ethereum node that signs the transactions and
+// messages of AWS KMS wallets and passes every other request through to the node.
+//
+//	kms-rpc-proxy -keys 1234abcd-12ab-34cd-56ef-1234567890ab,alias/treasury -upstream http://127.0.0.1:8545 -http 127.0.0.1:8546
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/internal/ethrpc"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/rpcproxy"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/ethereum/go-ethereum/rpc"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	keys := flag.String("keys", "", "comma separated keyIds or aliases (with the alias/ prefix) of the wallets to sign with")
+	upstream := flag.String("upstream", "", "JSON-RPC URL of the upstream node")
+	httpAddr := flag.String("http", "127.0.0.1:8546", "HTTP listen address")
+	vhosts := flag.String("http.vhosts", "localhost", "comma separated host names accepted in the Host header, * allows any")
+	cors := flag.String("http.corsdomain", "", "comma separated origins allowed to send cross-origin requests, * allows any")
+	region := flag.String("region", "", "AWS region, defaults to the AWS SDK configuration")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if err := run(logger, *keys, *upstream, *httpAddr, *vhosts, *cors, *region); err != nil {
+		logger.Error("kms-rpc-proxy failed", slog.Any("err", err))
+		os.Exit(1)
+	}
+}
+
+func run(logger *slog.Logger, keys string, upstreamURL string, httpAddr string, vhosts string, cors string, region string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if keys == "" {
+		return errors.New("at least one key is required")
+	}
+
+	if upstreamURL == "" {
+		return errors.New("upstream is required")
+	}
+
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	upstream, err := rpc.DialContext(ctx, upstreamURL)
+	if err != nil {
+		return err
+	}
+
+	defer upstream.Close()
+
+	provider := kmswallet.NewProvider(kms.NewFromConfig(awsConfig), nil, kmswallet.WithLogger(logger))
+	proxy, err := rpcproxy.NewProxy(ctx, provider, upstream, strings.Split(keys, ","), rpcproxy.WithLogger(logger))
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{Addr: httpAddr, Handler: ethrpc.HTTPHandler(proxy, cors, vhosts)}
+	go func() {
+		<-ctx.Done()
+		_ = httpServer.Close()
+	}()
+
+	logger.Info("serving HTTP", slog.String("addr", httpAddr), slog.String("upstream", upstreamURL))
+	if err = httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"math/big"
+	"mime"
+	"strings"
+)
+
+// externalAPIVersion is the Clef external API version the server implements.
+const externalAPIVersion = "6.1.0"
+
+var errUnknownAccount = errors.New("unknown account")
+
+// signTransactionResult is the account_signTransaction result, as Clef returns it.
+type signTransactionResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// accountAPI implements the Clef external signer API, registered under the "account" namespace, with KMS wallets.
+type accountAPI struct {
+	provider  kmswallet.Provider
+	chainId   *big.Int
+	addresses []common.Address
+	keyIds    map[common.Address]string
+}
+
+// newAccountAPI resolves the addresses of keys, which are keyIds or aliases with the "alias/" prefix.
+func newAccountAPI(ctx context.Context, provider kmswallet.Provider, chainId *big.Int, keys []string) (*accountAPI, error) {
+	api := &accountAPI{
+		provider: provider,
+		chainId:  chainId,
+		keyIds:   map[common.Address]string{},
+	}
+
+	for _, key := range keys {
+		keyId := key
+		if alias, ok := strings.CutPrefix(key, "alias/"); ok {
+			var err error
+			if keyId, err = provider.GetKeyIdByAlias(ctx, alias); err != nil {
+				return nil, err
+			}
+		}
+
+		wallet, err := provider.GetWallet(ctx, keyId)
+		if err != nil {
+			return nil, err
+		}
+
+		address := common.HexToAddress(wallet.Address)
+		if _, ok := api.keyIds[address]; ok {
+			continue
+		}
+
+		api.addresses = append(api.addresses, address)
+		api.keyIds[address] = keyId
+	}
+
+	return api, nil
+}
+
+// List returns the addresses of the KMS wallets (account_list).
+func (api *accountAPI) List(_ context.Context) ([]common.Address, error) {
+	return api.addresses, nil
+}
+
+// Version returns the external API version (account_version).
+func (api *accountAPI) Version(_ context.Context) (string, error) {
+	return externalAPIVersion, nil
+}
+
+// SignTransaction signs the transaction described by args with the wallet of args.From (account_signTransaction).
+// Requests for a chain other than the configured one are refused.
+func (api *accountAPI) SignTransaction(ctx context.Context, args apitypes.SendTxArgs, _ *string) (*signTransactionResult, error) {
+	keyId, err := api.keyId(args.From.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	if args.ChainID != nil && api.chainId.Cmp(args.ChainID.ToInt()) != 0 {
+		return nil, fmt.Errorf("requested chainid %d does not match the configuration of the signer", args.ChainID.ToInt())
+	}
+
+	args.ChainID = (*hexutil.Big)(api.chainId)
+	tx, err := args.ToTransaction()
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, err := api.provider.SignTransactionForChain(ctx, keyId, api.chainId, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &signTransactionResult{Raw: raw, Tx: signedTx}, nil
+}
+
+// SignData signs data according to contentType (account_signData): text/plain with the
+// "\x19Ethereum Signed Message:\n" prefix, data/typed as EIP-712 typed data and data/validator as EIP-191 version 0
+// data. Signatures have V as 27 or 28.
+func (api *accountAPI) SignData(ctx context.Context, contentType string, addr common.MixedcaseAddress, data interface{}) (hexutil.Bytes, error) {
+	keyId, err := api.keyId(addr.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case apitypes.TextPlain.Mime:
+		message, err := fromHex(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.provider.SignMessage(ctx, keyId, message)
+	case apitypes.DataTyped.Mime:
+		var typedData apitypes.TypedData
+		if err = remarshal(data, &typedData); err != nil {
+			return nil, err
+		}
+
+		return api.provider.SignTypedData(ctx, keyId, typedData)
+	case apitypes.IntendedValidator.Mime:
+		var validatorData struct {
+			Address common.Address `json:"address"`
+			Message hexutil.Bytes  `json:"message"`
+		}
+
+		if err = remarshal(data, &validatorData); err != nil {
+			return nil, err
+		}
+
+		hash := crypto.Keccak256([]byte{0x19, 0x00}, validatorData.Address.Bytes(), validatorData.Message)
+		return api.provider.SignHash(ctx, keyId, hash)
+	default:
+		return nil, fmt.Errorf("unsupported content type: %s", contentType)
+	}
+}
+
+// SignTypedData signs EIP-712 typed data (account_signTypedData).
+func (api *accountAPI) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, typedData apitypes.TypedData) (hexutil.Bytes, error) {
+	keyId, err := api.keyId(addr.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	return api.provider.SignTypedData(ctx, keyId, typedData)
+}
+
+func (api *accountAPI) keyId(address common.Address) (string, error) {
+	keyId, ok := api.keyIds[address]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", errUnknownAccount, address)
+	}
+
+	return keyId, nil
+}
+
+func fromHex(data interface{}) ([]byte, error) {
+	text, ok := data.(string)
+	if !ok {
+		return nil, fmt.Errorf("data is required to be a hex encoded string for %s", accounts.MimetypeTextPlain)
+	}
+
+	return hexutil.Decode(text)
+}
+
+// remarshal converts the generically decoded JSON data to target.
+func remarshal(data interface{}, target interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, target)
+}
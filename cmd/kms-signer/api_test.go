@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/internal/ethrpc"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testChainId = big.NewInt(5)
+
+func newTestServer(t *testing.T) (*kmstest.Client, string, string, *rpc.Server) {
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	aliasKeyId := client.AddKey(t)
+	client.AddAlias("treasury", aliasKeyId)
+
+	server, err := newServer(context.Background(), kmswallet.NewProvider(client, nil), testChainId, []string{keyId, "alias/treasury"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(server.Stop)
+	return client, keyId, aliasKeyId, server
+}
+
+func newTestRPCClient(t *testing.T) (*kmstest.Client, string, string, *rpc.Client) {
+	client, keyId, aliasKeyId, server := newTestServer(t)
+	return client, keyId, aliasKeyId, rpc.DialInProc(server)
+}
+
+func newTestExternalSigner(t *testing.T) (*kmstest.Client, string, *external.ExternalSigner) {
+	client, keyId, _, server := newTestServer(t)
+	httpServer := httptest.NewServer(ethrpc.HTTPHandler(server, "", "localhost"))
+	t.Cleanup(httpServer.Close)
+
+	signer, err := external.NewExternalSigner(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, keyId, signer
+}
+
+func TestAccountList(t *testing.T) {
+	// given
+	client, keyId, aliasKeyId, rpcClient := newTestRPCClient(t)
+
+	// when
+	var addresses []common.Address
+	err := rpcClient.Call(&addresses, "account_list")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{client.Address(keyId), client.Address(aliasKeyId)}, addresses)
+}
+
+func TestHTTPHandler_Should_Refuse_Other_Hosts_Origins_And_Content_Types(t *testing.T) {
+	// given
+	_, _, _, server := newTestServer(t)
+	handler := ethrpc.HTTPHandler(server, "", "localhost")
+	body := `{"jsonrpc":"2.0","id":1,"method":"account_list"}`
+
+	newRequest := func(host string, contentType string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "http://"+host+"/", strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		request.Header.Set("Origin", "https://attacker.example")
+		return request
+	}
+
+	// when
+	localhost := httptest.NewRecorder()
+	handler.ServeHTTP(localhost, newRequest("localhost:8550", "application/json"))
+
+	rebound := httptest.NewRecorder()
+	handler.ServeHTTP(rebound, newRequest("attacker.example:8550", "application/json"))
+
+	plainText := httptest.NewRecorder()
+	handler.ServeHTTP(plainText, newRequest("localhost:8550", "text/plain"))
+
+	// then
+	assert.Equal(t, http.StatusOK, localhost.Code)
+	assert.Empty(t, localhost.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, http.StatusForbidden, rebound.Code)
+	assert.Equal(t, http.StatusUnsupportedMediaType, plainText.Code)
+}
+
+func TestNewServer_When_Alias_Is_Not_Found(t *testing.T) {
+	// when
+	server, err := newServer(context.Background(), kmswallet.NewProvider(kmstest.NewClient(), nil), testChainId, []string{"alias/missing"})
+
+	// then
+	assert.ErrorContains(t, err, "alias: missing")
+	assert.Nil(t, server)
+}
+
+func TestExternalSigner_SignTx(t *testing.T) {
+	// given
+	client, keyId, signer := newTestExternalSigner(t)
+	account := accounts.Account{Address: client.Address(keyId)}
+	to := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   testChainId,
+		Nonce:     3,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(30e9),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(1e18),
+	})
+
+	// when
+	signedTx, err := signer.SignTx(account, tx, testChainId)
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, signer.Contains(account))
+	assert.Equal(t, types.LatestSignerForChainID(testChainId).Hash(tx), types.LatestSignerForChainID(testChainId).Hash(signedTx))
+
+	sender, err := types.Sender(types.LatestSignerForChainID(testChainId), signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, account.Address, sender)
+}
+
+func TestExternalSigner_SignTx_Legacy(t *testing.T) {
+	// given
+	client, keyId, signer := newTestExternalSigner(t)
+	account := accounts.Account{Address: client.Address(keyId)}
+	to := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+	tx := types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(20e9), nil)
+
+	// when
+	signedTx, err := signer.SignTx(account, tx, testChainId)
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, signedTx.Protected())
+	sender, err := types.Sender(types.NewEIP155Signer(testChainId), signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, account.Address, sender)
+}
+
+func TestExternalSigner_SignText(t *testing.T) {
+	// given
+	client, keyId, signer := newTestExternalSigner(t)
+	account := accounts.Account{Address: client.Address(keyId)}
+	text := []byte("hello clef")
+
+	// when
+	signature, err := signer.SignText(account, text)
+
+	// then
+	assert.NoError(t, err)
+	publicKey, err := crypto.SigToPub(accounts.TextHash(text), signature)
+	assert.NoError(t, err)
+	assert.Equal(t, account.Address, crypto.PubkeyToAddress(*publicKey))
+}
+
+func TestAccountSignTransaction_When_Chain_Id_Does_Not_Match(t *testing.T) {
+	// given
+	client, keyId, _, rpcClient := newTestRPCClient(t)
+	args := apitypes.SendTxArgs{
+		From:     common.NewMixedcaseAddress(client.Address(keyId)),
+		Gas:      21000,
+		GasPrice: (*hexutil.Big)(big.NewInt(1)),
+		ChainID:  (*hexutil.Big)(big.NewInt(1)),
+	}
+
+	// when
+	var result signTransactionResult
+	err := rpcClient.Call(&result, "account_signTransaction", args)
+
+	// then
+	assert.ErrorContains(t, err, "requested chainid 1 does not match the configuration of the signer")
+}
+
+func TestAccountSignData_When_Account_Is_Unknown(t *testing.T) {
+	// given
+	_, _, _, rpcClient := newTestRPCClient(t)
+	address := common.NewMixedcaseAddress(common.HexToAddress("0x01"))
+
+	// when
+	var signature hexutil.Bytes
+	err := rpcClient.Call(&signature, "account_signData", accounts.MimetypeTextPlain, &address, hexutil.Encode([]byte("hello")))
+
+	// then
+	assert.ErrorContains(t, err, "unknown account")
+}
+
+func TestAccountSignData_Validator(t *testing.T) {
+	// given
+	client, _, aliasKeyId, rpcClient := newTestRPCClient(t)
+	address := common.NewMixedcaseAddress(client.Address(aliasKeyId))
+	validator := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	message := []byte{0xca, 0xfe}
+
+	// when
+	var signature hexutil.Bytes
+	err := rpcClient.Call(&signature, "account_signData", apitypes.IntendedValidator.Mime, &address, map[string]interface{}{
+		"address": validator,
+		"message": hexutil.Encode(message),
+	})
+
+	// then
+	assert.NoError(t, err)
+	signer, err := kmswallet.RecoverAddress(crypto.Keccak256([]byte{0x19, 0x00}, validator.Bytes(), message), signature)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(aliasKeyId), signer)
+}
+
+func TestAccountSignTypedData(t *testing.T) {
+	// given
+	client, keyId, _, rpcClient := newTestRPCClient(t)
+	address := common.NewMixedcaseAddress(client.Address(keyId))
+	typedData := kmswallet.PermitTypedData(client.Address(keyId), kmswallet.PermitToken{
+		Address: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+		Name:    "USD Coin",
+		Version: "2",
+	}, common.HexToAddress("0x01"), big.NewInt(1), big.NewInt(0), big.NewInt(1_700_000_000), testChainId)
+
+	// when
+	var signature, dataSignature hexutil.Bytes
+	err := rpcClient.Call(&signature, "account_signTypedData", &address, typedData)
+	dataErr := rpcClient.Call(&dataSignature, "account_signData", apitypes.DataTyped.Mime, &address, typedData)
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, dataErr)
+	for _, sig := range []hexutil.Bytes{signature, dataSignature} {
+		valid, err := kmswallet.VerifyTypedDataSignature(client.Address(keyId), typedData, sig)
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	}
+}
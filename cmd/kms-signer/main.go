@@ -0,0 +1,120 @@
+// Command kms-signer serves the Clef external signer API with AWS KMS wallets, so that geth, Foundry and other tools
+// with external signer support can sign with them over HTTP or IPC.
+//
+//	kms-signer -keys 1234abcd-12ab-34cd-56ef-1234567890ab,alias/treasury -chainid 1 -http 127.0.0.1:8550
+//	geth --signer http://127.0.0.1:8550 ...
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/internal/ethrpc"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/ethereum/go-ethereum/rpc"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	keys := flag.String("keys", "", "comma separated keyIds or aliases (with the alias/ prefix) of the wallets to serve")
+	chainId := flag.Int64("chainid", 1, "chain id of the transactions to sign")
+	httpAddr := flag.String("http", "127.0.0.1:8550", "HTTP listen address, empty to disable")
+	vhosts := flag.String("http.vhosts", "localhost", "comma separated host names accepted in the Host header, * allows any")
+	cors := flag.String("http.corsdomain", "", "comma separated origins allowed to send cross-origin requests, * allows any")
+	ipcPath := flag.String("ipc", "", "IPC socket path, empty to disable")
+	region := flag.String("region", "", "AWS region, defaults to the AWS SDK configuration")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if err := run(logger, strings.Split(*keys, ","), big.NewInt(*chainId), *httpAddr, *vhosts, *cors, *ipcPath, *region); err != nil {
+		logger.Error("kms-signer failed", slog.Any("err", err))
+		os.Exit(1)
+	}
+}
+
+func run(logger *slog.Logger, keys []string, chainId *big.Int, httpAddr string, vhosts string, cors string, ipcPath string, region string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(keys) == 0 || keys[0] == "" {
+		return errors.New("at least one key is required")
+	}
+
+	if httpAddr == "" && ipcPath == "" {
+		return errors.New("at least one of HTTP and IPC is required")
+	}
+
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	provider := kmswallet.NewProvider(kms.NewFromConfig(awsConfig), nil, kmswallet.WithLogger(logger))
+	server, err := newServer(ctx, provider, chainId, keys)
+	if err != nil {
+		return err
+	}
+
+	defer server.Stop()
+
+	errs := make(chan error, 2)
+	if httpAddr != "" {
+		httpServer := &http.Server{Addr: httpAddr, Handler: ethrpc.HTTPHandler(server, cors, vhosts)}
+		defer httpServer.Close()
+
+		logger.Info("serving HTTP", slog.String("addr", httpAddr))
+		go func() {
+			errs <- httpServer.ListenAndServe()
+		}()
+	}
+
+	if ipcPath != "" {
+		listener, err := net.Listen("unix", ipcPath)
+		if err != nil {
+			return err
+		}
+
+		defer listener.Close()
+
+		logger.Info("serving IPC", slog.String("path", ipcPath))
+		go func() {
+			errs <- server.ServeListener(listener)
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err = <-errs:
+		return err
+	}
+}
+
+// newServer returns a JSON-RPC server with the account API of the wallets of keys.
+func newServer(ctx context.Context, provider kmswallet.Provider, chainId *big.Int, keys []string) (*rpc.Server, error) {
+	api, err := newAccountAPI(ctx, provider, chainId, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	server := rpc.NewServer()
+	if err = server.RegisterName("account", api); err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
@@ -0,0 +1,77 @@
+// Command kms-web3signer serves the Web3Signer eth1 HTTP API with AWS KMS wallets.
+//
+//	kms-web3signer -keys 1234abcd-12ab-34cd-56ef-1234567890ab,alias/treasury -chainid 1 -http 127.0.0.1:9000
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/internal/ethrpc"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/web3signer"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	keys := flag.String("keys", "", "comma separated keyIds or aliases (with the alias/ prefix) of the wallets to serve")
+	chainId := flag.Int64("chainid", 1, "chain id of the transactions to sign")
+	httpAddr := flag.String("http", "127.0.0.1:9000", "HTTP listen address")
+	vhosts := flag.String("http.vhosts", "localhost", "comma separated host names accepted in the Host header, * allows any")
+	cors := flag.String("http.corsdomain", "", "comma separated origins allowed to send cross-origin requests, * allows any")
+	region := flag.String("region", "", "AWS region, defaults to the AWS SDK configuration")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if err := run(logger, *keys, big.NewInt(*chainId), *httpAddr, *vhosts, *cors, *region); err != nil {
+		logger.Error("kms-web3signer failed", slog.Any("err", err))
+		os.Exit(1)
+	}
+}
+
+func run(logger *slog.Logger, keys string, chainId *big.Int, httpAddr string, vhosts string, cors string, region string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	var keyList []string
+	if keys != "" {
+		keyList = strings.Split(keys, ",")
+	}
+
+	provider := kmswallet.NewProvider(kms.NewFromConfig(awsConfig), nil, kmswallet.WithLogger(logger))
+	server, err := web3signer.NewServer(ctx, provider, chainId, keyList, web3signer.WithLogger(logger))
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{Addr: httpAddr, Handler: ethrpc.HTTPHandler(server, cors, vhosts)}
+	go func() {
+		<-ctx.Done()
+		_ = httpServer.Close()
+	}()
+
+	logger.Info("serving HTTP", slog.String("addr", httpAddr))
+	if err = httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/internal/ethrpc"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ether_types "github.com/ethereum/go-ethereum/core/types"
+	"io"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var keyIdPattern = regexp.MustCompile(`^(mrk-[0-9a-f]{32}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+
+// wallet is the JSON output of the wallet commands.
+type wallet struct {
+	Address      string     `json:"address,omitempty"`
+	KeyId        string     `json:"keyId"`
+	Arn          string     `json:"arn,omitempty"`
+	Aliases      []string   `json:"aliases,omitempty"`
+	KeyState     string     `json:"keyState,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	CreationDate *time.Time `json:"creationDate,omitempty"`
+	DeletionDate *time.Time `json:"deletionDate,omitempty"`
+}
+
+func newWallet(details kmswallet.WalletDetails) wallet {
+	return wallet{
+		Address:      details.Address,
+		KeyId:        details.KeyId,
+		Arn:          details.Arn,
+		Aliases:      details.Aliases,
+		KeyState:     string(details.KeyState),
+		Description:  details.Description,
+		CreationDate: details.CreationDate,
+		DeletionDate: details.DeletionDate,
+	}
+}
+
+func (w wallet) writeText(out io.Writer) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fields := [][2]string{
+		{"Address", w.Address},
+		{"Key ID", w.KeyId},
+		{"ARN", w.Arn},
+		{"Aliases", strings.Join(w.Aliases, ", ")},
+		{"Key state", w.KeyState},
+		{"Description", w.Description},
+		{"Created", formatTime(w.CreationDate)},
+		{"Deletion date", formatTime(w.DeletionDate)},
+	}
+
+	for _, field := range fields {
+		if field[1] != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", field[0], field[1])
+		}
+	}
+
+	_ = tw.Flush()
+}
+
+// tagsFlag collects repeated key=value flags.
+type tagsFlag map[string]string
+
+func (f tagsFlag) String() string {
+	var tags []string
+	for key, value := range f {
+		tags = append(tags, key+"="+value)
+	}
+
+	return strings.Join(tags, ",")
+}
+
+func (f tagsFlag) Set(value string) error {
+	key, tagValue, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("tag %q is required to be key=value", value)
+	}
+
+	f[key] = tagValue
+	return nil
+}
+
+func (a *app) create(ctx context.Context, args []string) error {
+	fs, opts := a.flagSet()
+	tags := tagsFlag{}
+	alias := fs.String("alias", "", "alias of the key, without the alias/ prefix")
+	noAddressAlias := fs.Bool("no-address-alias", false, "do not create the default alias of the wallet address when -alias is not set")
+	addressTag := fs.Bool("address-tag", false, "tag the key with the wallet address")
+	bypassPolicyLockoutSafetyCheck := fs.Bool("bypass-policy-lockout-safety-check", false, "skip the KMS key policy lockout safety check")
+	customKeyStoreId := fs.String("custom-key-store-id", "", "custom key store to create the key in")
+	description := fs.String("description", "", "description of the key")
+	multiRegion := fs.Bool("multi-region", false, "create a multi-Region primary key")
+	origin := fs.String("origin", "", "origin of the key material: AWS_KMS, EXTERNAL, AWS_CLOUDHSM or EXTERNAL_KEY_STORE")
+	policy := fs.String("policy", "", "key policy as JSON, or @file to read it from a file")
+	xksKeyId := fs.String("xks-key-id", "", "external key of the key in an external key store")
+	fs.Var(tags, "tag", "key=value tag of the key, can be repeated")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	input := kmswallet.CreateWalletInput{
+		Alias:                           optionalString(*alias),
+		IgnoreDefaultWalletAddressAlias: *noAddressAlias,
+		AddWalletAddressTag:             *addressTag,
+		BypassPolicyLockoutSafetyCheck:  *bypassPolicyLockoutSafetyCheck,
+		CustomKeyStoreId:                optionalString(*customKeyStoreId),
+		Description:                     optionalString(*description),
+		Origin:                          types.OriginType(*origin),
+		Tags:                            tags,
+		XksKeyId:                        optionalString(*xksKeyId),
+	}
+
+	if *multiRegion {
+		input.MultiRegion = multiRegion
+	}
+
+	if path, ok := strings.CutPrefix(*policy, "@"); ok {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		input.Policy = optionalString(string(content))
+	} else {
+		input.Policy = optionalString(*policy)
+	}
+
+	provider, err := a.provider(ctx, opts.region)
+	if err != nil {
+		return err
+	}
+
+	created, err := provider.CreateWallet(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	return a.printWallet(ctx, opts, provider, created.KeyId)
+}
+
+func (a *app) get(ctx context.Context, args []string) error {
+	fs, opts := a.flagSet()
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	provider, keyId, err := a.resolve(ctx, opts, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return a.printWallet(ctx, opts, provider, keyId)
+}
+
+func (a *app) list(ctx context.Context, args []string) error {
+	fs, opts := a.flagSet()
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	provider, err := a.provider(ctx, opts.region)
+	if err != nil {
+		return err
+	}
+
+	details, err := provider.ListWallets(ctx)
+	if err != nil {
+		return err
+	}
+
+	wallets := make([]wallet, len(details))
+	for i, d := range details {
+		wallets[i] = newWallet(d)
+	}
+
+	if opts.json {
+		return a.printJSON(wallets)
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tKEY ID\tSTATE\tALIASES")
+	for _, w := range wallets {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", valueOr(w.Address, "-"), w.KeyId, w.KeyState, strings.Join(w.Aliases, ", "))
+	}
+
+	return tw.Flush()
+}
+
+func (a *app) enable(ctx context.Context, args []string) error {
+	return a.setEnabled(ctx, args, true)
+}
+
+func (a *app) disable(ctx context.Context, args []string) error {
+	return a.setEnabled(ctx, args, false)
+}
+
+func (a *app) setEnabled(ctx context.Context, args []string, enabled bool) error {
+	fs, opts := a.flagSet()
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	provider, keyId, err := a.resolve(ctx, opts, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		_, err = provider.EnableWallet(ctx, keyId)
+	} else {
+		_, err = provider.DisableWallet(ctx, keyId)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return a.printWallet(ctx, opts, provider, keyId)
+}
+
+func (a *app) scheduleDelete(ctx context.Context, args []string) error {
+	fs, opts := a.flagSet()
+	days := fs.Int("days", 30, "waiting period in days, between 7 and 30, before the key is deleted")
+	yes := fs.Bool("yes", false, "confirm the deletion")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	if !*yes {
+		return errors.New("the wallet can not be recovered once its key is deleted; pass -yes to schedule the deletion")
+	}
+
+	provider, keyId, err := a.resolve(ctx, opts, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if _, err = provider.ScheduleWalletDeletion(ctx, keyId, int32(*days)); err != nil {
+		return err
+	}
+
+	return a.printWallet(ctx, opts, provider, keyId)
+}
+
+func (a *app) signMessage(ctx context.Context, args []string) error {
+	fs, opts := a.flagSet()
+	isHex := fs.Bool("hex", false, "the message is hex encoded bytes")
+	if err := parse(fs, args, 2, 2); err != nil {
+		return err
+	}
+
+	message := []byte(fs.Arg(1))
+	if fs.Arg(1) == "-" {
+		var err error
+		if message, err = io.ReadAll(a.stdin); err != nil {
+			return err
+		}
+	}
+
+	if *isHex {
+		var err error
+		if message, err = hexutil.Decode(strings.TrimSpace(string(message))); err != nil {
+			return fmt.Errorf("can not decode message: %w", err)
+		}
+	}
+
+	provider, keyId, err := a.resolve(ctx, opts, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	address, err := a.walletAddress(ctx, provider, keyId)
+	if err != nil {
+		return err
+	}
+
+	signature, err := provider.SignMessage(ctx, keyId, message)
+	if err != nil {
+		return err
+	}
+
+	hash := common.BytesToHash(accounts.TextHash(message))
+	return a.print(opts, struct {
+		Address   common.Address `json:"address"`
+		Hash      common.Hash    `json:"hash"`
+		Signature hexutil.Bytes  `json:"signature"`
+	}{address, hash, signature}, [][2]string{
+		{"Address", address.Hex()},
+		{"Hash", hash.Hex()},
+		{"Signature", hexutil.Encode(signature)},
+	})
+}
+
+func (a *app) signTx(ctx context.Context, args []string) error {
+	fs, opts := a.flagSet()
+	chainIdFlag := fs.String("chainid", "", "chain id, defaults to the chain id of the transaction")
+	if err := parse(fs, args, 1, 2); err != nil {
+		return err
+	}
+
+	var input []byte
+	var err error
+	if fs.NArg() == 1 || fs.Arg(1) == "-" {
+		input, err = io.ReadAll(a.stdin)
+	} else {
+		input, err = os.ReadFile(fs.Arg(1))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var chainId *big.Int
+	if *chainIdFlag != "" {
+		var ok bool
+		if chainId, ok = new(big.Int).SetString(*chainIdFlag, 0); !ok {
+			return fmt.Errorf("invalid chain id: %s", *chainIdFlag)
+		}
+	}
+
+	provider, keyId, err := a.resolve(ctx, opts, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	address, err := a.walletAddress(ctx, provider, keyId)
+	if err != nil {
+		return err
+	}
+
+	tx, chainId, err := parseTransaction(bytes.TrimSpace(input), address, chainId)
+	if err != nil {
+		return err
+	}
+
+	signedTx, err := provider.SignTransactionForChain(ctx, keyId, chainId, tx)
+	if err != nil {
+		return err
+	}
+
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return a.print(opts, struct {
+		From common.Address `json:"from"`
+		Hash common.Hash    `json:"hash"`
+		Raw  hexutil.Bytes  `json:"raw"`
+	}{address, signedTx.Hash(), raw}, [][2]string{
+		{"From", address.Hex()},
+		{"Hash", signedTx.Hash().Hex()},
+		{"Raw", hexutil.Encode(raw)},
+	})
+}
+
+func (a *app) address(ctx context.Context, args []string) error {
+	fs, opts := a.flagSet()
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	provider, keyId, err := a.resolve(ctx, opts, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	address, err := a.walletAddress(ctx, provider, keyId)
+	if err != nil {
+		return err
+	}
+
+	if opts.json {
+		return a.printJSON(map[string]common.Address{"address": address})
+	}
+
+	_, err = fmt.Fprintln(a.stdout, address.Hex())
+	return err
+}
+
+// resolve returns the provider and the keyId of a keyId, key ARN, alias or address.
+func (a *app) resolve(ctx context.Context, opts *options, key string) (kmswallet.Provider, string, error) {
+	provider, err := a.provider(ctx, opts.region)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case keyIdPattern.MatchString(key) || strings.HasPrefix(key, "arn:"):
+		return provider, key, nil
+	case common.IsHexAddress(key):
+		address := common.HexToAddress(key)
+		keyId, err := provider.GetKeyIdByAliasWithAddress(ctx, address.Hex(), address)
+		if err != nil {
+			return nil, "", fmt.Errorf("no wallet found for address %s: %w", address, err)
+		}
+
+		return provider, keyId, nil
+	default:
+		keyId, err := provider.GetKeyIdByAlias(ctx, strings.TrimPrefix(key, "alias/"))
+		return provider, keyId, err
+	}
+}
+
+func (a *app) walletAddress(ctx context.Context, provider kmswallet.Provider, keyId string) (common.Address, error) {
+	w, err := provider.GetWallet(ctx, keyId)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return common.HexToAddress(w.Address), nil
+}
+
+func (a *app) printWallet(ctx context.Context, opts *options, provider kmswallet.Provider, keyId string) error {
+	details, err := provider.GetWalletDetails(ctx, keyId)
+	if err != nil {
+		return err
+	}
+
+	w := newWallet(details)
+	if opts.json {
+		return a.printJSON(w)
+	}
+
+	w.writeText(a.stdout)
+	return nil
+}
+
+// print writes value as JSON, or fields as aligned text lines.
+func (a *app) print(opts *options, value interface{}, fields [][2]string) error {
+	if opts.json {
+		return a.printJSON(value)
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	for _, field := range fields {
+		fmt.Fprintf(tw, "%s:\t%s\n", field[0], field[1])
+	}
+
+	return tw.Flush()
+}
+
+func (a *app) printJSON(value interface{}) error {
+	encoder := json.NewEncoder(a.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// parseTransaction decodes a transaction given as eth_signTransaction JSON arguments or as hex encoded RLP. The chain
+// id of typed and JSON transactions is used when chainId is nil, and has to match it otherwise.
+func parseTransaction(input []byte, from common.Address, chainId *big.Int) (*ether_types.Transaction, *big.Int, error) {
+	var txChainId *big.Int
+	var tx *ether_types.Transaction
+	if len(input) > 0 && input[0] == '{' {
+		var args ethrpc.TransactionArgs
+		if err := json.Unmarshal(input, &args); err != nil {
+			return nil, nil, fmt.Errorf("can not decode transaction: %w", err)
+		}
+
+		if args.From != nil && *args.From != from {
+			return nil, nil, fmt.Errorf("transaction is from %s, not %s", args.From, from)
+		}
+
+		args.From = &from
+		if args.ChainId != nil {
+			txChainId = args.ChainId.ToInt()
+		}
+
+		if chainId == nil {
+			chainId = txChainId
+		}
+
+		if chainId == nil {
+			return nil, nil, errors.New("-chainid is required when the transaction has no chainId")
+		}
+
+		var err error
+		if tx, err = args.ToTransaction(chainId); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		encoded, err := hexutil.Decode(string(input))
+		if errors.Is(err, hexutil.ErrMissingPrefix) {
+			encoded, err = hexutil.Decode("0x" + string(input))
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("can not decode transaction: %w", err)
+		}
+
+		tx = new(ether_types.Transaction)
+		if err = tx.UnmarshalBinary(encoded); err != nil {
+			return nil, nil, fmt.Errorf("can not decode transaction: %w", err)
+		}
+
+		if tx.Type() != ether_types.LegacyTxType {
+			txChainId = tx.ChainId()
+		}
+	}
+
+	if chainId == nil {
+		chainId = txChainId
+	}
+
+	switch {
+	case chainId == nil:
+		return nil, nil, errors.New("-chainid is required for legacy transactions")
+	case txChainId != nil && txChainId.Cmp(chainId) != 0:
+		return nil, nil, fmt.Errorf("transaction chain id %s does not match -chainid %s", txChainId, chainId)
+	}
+
+	return tx, chainId, nil
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+func valueOr(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
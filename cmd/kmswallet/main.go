@@ -0,0 +1,156 @@
+// Command kmswallet creates, inspects and manages KMS wallets and signs messages and transactions with them.
+//
+//	kmswallet create -alias treasury -tag team=payments
+//	kmswallet list -json
+//	kmswallet sign-tx -chainid 1 alias/treasury tx.json
+//
+// Keys are referenced by keyId or key ARN, by alias (with or without the "alias/" prefix) or by address. Addresses are
+// looked up through the address alias CreateWallet creates by default. AWS credentials and region come from the
+// default AWS SDK configuration.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+type command struct {
+	usage       string
+	description string
+	run         func(a *app, ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"create":          {"create [flags]", "create a wallet", (*app).create},
+	"get":             {"get [flags] <key>", "show the key state, aliases and address of a wallet", (*app).get},
+	"list":            {"list [flags]", "list the wallets of the account and region", (*app).list},
+	"enable":          {"enable [flags] <key>", "enable a wallet", (*app).enable},
+	"disable":         {"disable [flags] <key>", "disable a wallet", (*app).disable},
+	"schedule-delete": {"schedule-delete [flags] -yes <key>", "schedule the deletion of the key of a wallet", (*app).scheduleDelete},
+	"sign-message":    {"sign-message [flags] <key> <message|->", "sign an EIP-191 personal message", (*app).signMessage},
+	"sign-tx":         {"sign-tx [flags] <key> [<file|->]", "sign a JSON or RLP encoded transaction", (*app).signTx},
+	"address":         {"address [flags] <key>", "print the address of a wallet", (*app).address},
+}
+
+// app runs the commands. provider is replaced in tests.
+type app struct {
+	name     string
+	command  command
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	provider func(ctx context.Context, region string) (kmswallet.Provider, error)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a := &app{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, provider: newProvider}
+	err := a.run(ctx, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kmswallet:", err)
+		os.Exit(1)
+	}
+}
+
+func newProvider(ctx context.Context, region string) (kmswallet.Provider, error) {
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return kmswallet.NewProvider(kms.NewFromConfig(awsConfig), nil), nil
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		a.usage()
+		if len(args) == 0 {
+			return errors.New("command is required")
+		}
+
+		return flag.ErrHelp
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		a.usage()
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+
+	a.name, a.command = args[0], cmd
+	return cmd.run(a, ctx, args[1:])
+}
+
+func (a *app) usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	fmt.Fprintln(a.stderr, "Usage: kmswallet <command> [flags] [args]")
+	fmt.Fprintln(a.stderr)
+	fmt.Fprintln(a.stderr, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(a.stderr, "  %-16s %s\n", name, commands[name].description)
+	}
+
+	fmt.Fprintln(a.stderr)
+	fmt.Fprintln(a.stderr, "Keys are keyIds, key ARNs, aliases or addresses. Run kmswallet <command> -h for the flags of a command.")
+}
+
+// options are the flags every command has.
+type options struct {
+	region string
+	json   bool
+}
+
+// flagSet returns the flags of the running command, with the common options already defined.
+func (a *app) flagSet() (*flag.FlagSet, *options) {
+	opts := &options{}
+	fs := flag.NewFlagSet(a.name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&opts.region, "region", "", "AWS region, defaults to the AWS SDK configuration")
+	fs.BoolVar(&opts.json, "json", false, "print JSON instead of text")
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: kmswallet %s\n\n%s.\n\nFlags:\n", a.command.usage, a.command.description)
+		fs.PrintDefaults()
+	}
+
+	return fs, opts
+}
+
+// parse parses the flags of fs and checks that between minArgs and maxArgs positional arguments are left.
+func parse(fs *flag.FlagSet, args []string, minArgs int, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < minArgs || fs.NArg() > maxArgs {
+		fs.Usage()
+		return fmt.Errorf("%s: wrong number of arguments", fs.Name())
+	}
+
+	return nil
+}
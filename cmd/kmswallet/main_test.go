@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+type testApp struct {
+	*app
+	client *kmstest.Client
+	stdin  *bytes.Buffer
+	stdout *bytes.Buffer
+}
+
+func newTestApp() *testApp {
+	client := kmstest.NewClient()
+	provider := kmswallet.NewProvider(client, nil)
+	a := &testApp{client: client, stdin: &bytes.Buffer{}, stdout: &bytes.Buffer{}}
+	a.app = &app{
+		stdin:  a.stdin,
+		stdout: a.stdout,
+		stderr: io.Discard,
+		provider: func(_ context.Context, _ string) (kmswallet.Provider, error) {
+			return provider, nil
+		},
+	}
+
+	return a
+}
+
+func (a *testApp) runJSON(t *testing.T, target interface{}, args ...string) error {
+	a.stdout.Reset()
+	if err := a.run(context.Background(), append(args[:1:1], append([]string{"-json"}, args[1:]...)...)); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(a.stdout.Bytes(), target); err != nil {
+		t.Fatal(err)
+	}
+
+	return nil
+}
+
+func TestCreate(t *testing.T) {
+	// given
+	a := newTestApp()
+
+	// when
+	var created wallet
+	err := a.runJSON(t, &created, "create", "-alias", "treasury", "-tag", "team=payments", "-address-tag", "-description", "hot wallet")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, a.client.Address(created.KeyId).Hex(), created.Address)
+	assert.Equal(t, []string{"treasury"}, created.Aliases)
+	assert.Equal(t, "Enabled", created.KeyState)
+	assert.Equal(t, "hot wallet", created.Description)
+	assert.Equal(t, map[string]string{"team": "payments", "walletAddress": created.Address}, a.client.Tags(created.KeyId))
+}
+
+func TestList(t *testing.T) {
+	// given
+	a := newTestApp()
+	keyId := a.client.AddKey(t)
+	a.client.AddAlias("treasury", keyId)
+	disabledKeyId := a.client.AddKey(t)
+	assert.NoError(t, a.run(context.Background(), []string{"disable", disabledKeyId}))
+
+	// when
+	var wallets []wallet
+	jsonErr := a.runJSON(t, &wallets, "list")
+
+	a.stdout.Reset()
+	textErr := a.run(context.Background(), []string{"list"})
+
+	// then
+	assert.NoError(t, jsonErr)
+	assert.Len(t, wallets, 2)
+	assert.Equal(t, a.client.Address(keyId).Hex(), wallets[0].Address)
+	assert.Equal(t, []string{"treasury"}, wallets[0].Aliases)
+	assert.Equal(t, disabledKeyId, wallets[1].KeyId)
+	assert.Equal(t, "Disabled", wallets[1].KeyState)
+
+	assert.NoError(t, textErr)
+	lines := strings.Split(strings.TrimSpace(a.stdout.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Regexp(t, `^ADDRESS\s+KEY ID\s+STATE\s+ALIASES$`, lines[0])
+	assert.Regexp(t, a.client.Address(keyId).Hex()+`\s+`+keyId+`\s+Enabled\s+treasury`, lines[1])
+}
+
+func TestAddress_Should_Resolve_KeyId_Alias_And_Address(t *testing.T) {
+	// given
+	a := newTestApp()
+	keyId := a.client.AddKey(t)
+	address := a.client.Address(keyId)
+	a.client.AddAlias("treasury", keyId)
+	a.client.AddAlias(address.Hex(), keyId)
+
+	for _, key := range []string{keyId, "treasury", "alias/treasury", address.Hex(), strings.ToLower(address.Hex())} {
+		// when
+		a.stdout.Reset()
+		err := a.run(context.Background(), []string{"address", key})
+
+		// then
+		assert.NoError(t, err, key)
+		assert.Equal(t, address.Hex()+"\n", a.stdout.String(), key)
+	}
+}
+
+func TestAddress_When_Address_Alias_Points_To_Another_Key(t *testing.T) {
+	// given
+	a := newTestApp()
+	address := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+	a.client.AddAlias(address.Hex(), a.client.AddKey(t))
+
+	// when
+	err := a.run(context.Background(), []string{"address", address.Hex()})
+
+	// then
+	assert.ErrorIs(t, err, kmswallet.ErrAddressMismatch)
+}
+
+func TestDisable_And_Enable(t *testing.T) {
+	// given
+	a := newTestApp()
+	keyId := a.client.AddKey(t)
+
+	// when
+	var disabled, enabled wallet
+	disableErr := a.runJSON(t, &disabled, "disable", keyId)
+	enableErr := a.runJSON(t, &enabled, "enable", keyId)
+
+	// then
+	assert.NoError(t, disableErr)
+	assert.Equal(t, "Disabled", disabled.KeyState)
+	assert.NoError(t, enableErr)
+	assert.Equal(t, "Enabled", enabled.KeyState)
+	assert.Equal(t, a.client.Address(keyId).Hex(), enabled.Address)
+}
+
+func TestScheduleDelete(t *testing.T) {
+	// given
+	a := newTestApp()
+	keyId := a.client.AddKey(t)
+
+	// when
+	unconfirmedErr := a.run(context.Background(), []string{"schedule-delete", keyId})
+
+	var scheduled wallet
+	err := a.runJSON(t, &scheduled, "schedule-delete", "-yes", "-days", "7", keyId)
+
+	// then
+	assert.ErrorContains(t, unconfirmedErr, "pass -yes to schedule the deletion")
+	assert.NoError(t, err)
+	assert.Equal(t, "PendingDeletion", scheduled.KeyState)
+	assert.NotNil(t, scheduled.DeletionDate)
+}
+
+func TestSignMessage(t *testing.T) {
+	// given
+	a := newTestApp()
+	keyId := a.client.AddKey(t)
+	a.stdin.WriteString("0xcafe\n")
+
+	// when
+	var text, hex struct {
+		Address   common.Address `json:"address"`
+		Signature hexutil.Bytes  `json:"signature"`
+	}
+	textErr := a.runJSON(t, &text, "sign-message", keyId, "hello")
+	hexErr := a.runJSON(t, &hex, "sign-message", "-hex", keyId, "-")
+
+	// then
+	assert.NoError(t, textErr)
+	assert.NoError(t, hexErr)
+	assert.Equal(t, a.client.Address(keyId), text.Address)
+
+	for message, signature := range map[string]hexutil.Bytes{"hello": text.Signature, "\xca\xfe": hex.Signature} {
+		signer, err := kmswallet.RecoverAddress(accounts.TextHash([]byte(message)), signature)
+		assert.NoError(t, err)
+		assert.Equal(t, a.client.Address(keyId), signer)
+	}
+}
+
+func TestSignTx(t *testing.T) {
+	// given
+	a := newTestApp()
+	keyId := a.client.AddKey(t)
+	to := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+	chainId := big.NewInt(10)
+	unsignedTx, _ := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainId,
+		Nonce:     2,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(30e9),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(1),
+	}).MarshalBinary()
+
+	inputs := map[string]string{
+		"json": `{"to": "` + to.Hex() + `", "gas": "0x5208", "gasPrice": "0x3b9aca00", "nonce": "0x1", "chainId": "0xa"}`,
+		"rlp":  hexutil.Encode(unsignedTx),
+	}
+
+	for name, input := range inputs {
+		a.stdin.Reset()
+		a.stdin.WriteString(input)
+
+		// when
+		var result struct {
+			From common.Address `json:"from"`
+			Hash common.Hash    `json:"hash"`
+			Raw  hexutil.Bytes  `json:"raw"`
+		}
+		err := a.runJSON(t, &result, "sign-tx", keyId)
+
+		// then
+		assert.NoError(t, err, name)
+		tx := new(types.Transaction)
+		assert.NoError(t, tx.UnmarshalBinary(result.Raw), name)
+		assert.Equal(t, result.Hash, tx.Hash(), name)
+		assert.Equal(t, chainId, tx.ChainId(), name)
+
+		sender, err := types.Sender(types.LatestSignerForChainID(chainId), tx)
+		assert.NoError(t, err, name)
+		assert.Equal(t, a.client.Address(keyId), sender, name)
+		assert.Equal(t, result.From, sender, name)
+	}
+}
+
+func TestSignTx_When_Chain_Id_Does_Not_Match(t *testing.T) {
+	// given
+	a := newTestApp()
+	keyId := a.client.AddKey(t)
+	a.stdin.WriteString(`{"gas": "0x5208", "gasPrice": "0x1", "nonce": "0x0", "chainId": "0x1"}`)
+
+	// when
+	err := a.run(context.Background(), []string{"sign-tx", "-chainid", "5", keyId})
+
+	// then
+	assert.ErrorContains(t, err, "chainId 1 does not match the chain id of the signer 5")
+}
@@ -0,0 +1,69 @@
+package kmswallet
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type signOptions struct {
+	compact bool
+}
+
+type SignOption func(o *signOptions)
+
+// WithCompactSignature makes the signing functions return the 64 bytes EIP-2098 compact signature
+// [R || yParityAndS] instead of the 65 bytes [R || S || V] form.
+func WithCompactSignature() SignOption {
+	return func(o *signOptions) {
+		o.compact = true
+	}
+}
+
+// ToCompactSignature converts a 65 bytes signature, with V as 0/1 or 27/28, to the 64 bytes EIP-2098 compact form.
+func ToCompactSignature(signature []byte) ([]byte, error) {
+	if len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("%w: length is required to be %d bytes (%d)", ErrInvalidSignature, crypto.SignatureLength, len(signature))
+	}
+
+	normalized, err := normalizeSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	compact := normalized[:64]
+	compact[32] |= normalized[64] << 7
+	return compact, nil
+}
+
+// FromCompactSignature converts a 64 bytes EIP-2098 compact signature to the 65 bytes [R || S || V] form with V as
+// 27 or 28.
+func FromCompactSignature(compact []byte) ([]byte, error) {
+	if len(compact) != crypto.SignatureLength-1 {
+		return nil, fmt.Errorf("%w: length is required to be %d bytes (%d)", ErrInvalidSignature, crypto.SignatureLength-1, len(compact))
+	}
+
+	signature, err := normalizeSignature(compact)
+	if err != nil {
+		return nil, err
+	}
+
+	signature[64] += 27
+	return signature, nil
+}
+
+// formatSignature turns a signature returned by signDigest into the form requested by opts.
+func formatSignature(signature []byte, opts []SignOption) []byte {
+	options := signOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.compact {
+		compact := signature[:64]
+		compact[32] |= signature[64] << 7
+		return compact
+	}
+
+	signature[64] += 27
+	return signature
+}
@@ -0,0 +1,100 @@
+package kmswallet_test
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSignMessage_Should_Return_Compact_Signature_When_Requested(t *testing.T) {
+	// given
+	mockClient := newSignMessageMockClient("MFYwEAYHKoZIzj0CAQYFK4EEAAoDQgAE1TWYYp+bySM6J3m99CxKhHZFgJqdwm5V6kGziuJ4kc7f0xORg5AqGRKbFNTSsmYTXNi2Z/cl298eyKTbmy+8aQ==")
+	provider := kmswallet.NewProvider(mockClient, nil)
+	fullSignature, _ := base64.StdEncoding.DecodeString("+sBHDs8o5fMK/jkfCJ+eG1SoEef2aIEEFohz5Km+9DVlmcaEhGV8aP3F7HEAJZDhZk8HrSzBk/kMro6PIQkghxw=")
+	expectedOutput := append([]byte(nil), fullSignature[:64]...)
+	expectedOutput[32] |= 0x80
+
+	// when
+	output, err := provider.SignMessage(context.Background(), "keyId", []byte("Hello World!"), kmswallet.WithCompactSignature())
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, expectedOutput, output)
+}
+
+func TestSignHash_Should_Return_Compact_Signature_When_Requested(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	hash := crypto.Keccak256([]byte("payload"))
+
+	// when
+	compact, err := provider.SignHash(context.Background(), keyId, hash, kmswallet.WithCompactSignature())
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, compact, 64)
+	address, err := kmswallet.RecoverAddress(hash, compact)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), address)
+}
+
+func TestSignTypedData(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	typedData := newTestTypedData()
+
+	// when
+	signature, err := provider.SignTypedData(context.Background(), keyId, typedData)
+	compact, errCompact := provider.SignTypedData(context.Background(), keyId, typedData, kmswallet.WithCompactSignature())
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, errCompact)
+	assert.Len(t, signature, 65)
+	assert.Len(t, compact, 64)
+
+	valid, err := kmswallet.VerifyTypedDataSignature(client.Address(keyId), typedData, signature)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	validCompact, err := kmswallet.VerifyTypedDataSignature(client.Address(keyId), typedData, compact)
+	assert.NoError(t, err)
+	assert.True(t, validCompact)
+}
+
+func TestCompactSignature_Conversions(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	for i := 0; i < 8; i++ {
+		hash := crypto.Keccak256([]byte{byte(i)})
+		signature, err := provider.SignHash(context.Background(), keyId, hash)
+		assert.NoError(t, err)
+
+		// when
+		compact, err := kmswallet.ToCompactSignature(signature)
+		assert.NoError(t, err)
+		roundTrip, err := kmswallet.FromCompactSignature(compact)
+		assert.NoError(t, err)
+
+		// then
+		assert.Len(t, compact, 64)
+		assert.Equal(t, signature, roundTrip)
+		assert.Equal(t, signature[64] == 28, compact[32]&0x80 != 0)
+	}
+}
+
+func TestCompactSignature_Conversions_Should_Reject_Invalid_Lengths(t *testing.T) {
+	// when
+	_, errToCompact := kmswallet.ToCompactSignature(make([]byte, 64))
+	_, errFromCompact := kmswallet.FromCompactSignature(make([]byte, 65))
+
+	// then
+	assert.ErrorIs(t, errToCompact, kmswallet.ErrInvalidSignature)
+	assert.ErrorIs(t, errFromCompact, kmswallet.ErrInvalidSignature)
+}
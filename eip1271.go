@@ -0,0 +1,153 @@
+package kmswallet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"math/big"
+	"strings"
+)
+
+// EIP1271MagicValue is the value isValidSignature(bytes32,bytes) returns for a valid signature.
+var EIP1271MagicValue = [4]byte{0x16, 0x26, 0xba, 0x7e}
+
+// delegationPrefix is the code prefix of EIP-7702 delegated EOAs.
+var delegationPrefix = []byte{0xef, 0x01, 0x00}
+
+var eip1271Abi = mustParseABI(`[{"type":"function","name":"isValidSignature","stateMutability":"view",` +
+	`"inputs":[{"name":"hash","type":"bytes32"},{"name":"signature","type":"bytes"}],"outputs":[{"name":"","type":"bytes4"}]}]`)
+
+// mustParseABI parses a constant ABI definition and panics when it is broken.
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("invalid ABI definition: %v", err))
+	}
+
+	return parsed
+}
+
+// SignatureVerifier verifies signatures of addresses that can be EOAs or EIP-1271 contracts, e.g. smart accounts.
+type SignatureVerifier struct {
+	caller bind.ContractCaller
+}
+
+func NewSignatureVerifier(caller bind.ContractCaller) *SignatureVerifier {
+	return &SignatureVerifier{caller: caller}
+}
+
+// VerifyHash reports whether signature is a valid signature of hash by address. Signatures of addresses without code
+// are recovered with ecrecover; for contracts, isValidSignature is called and a revert is reported as invalid.
+// EIP-7702 delegated EOAs are accepted with either.
+func (v *SignatureVerifier) VerifyHash(ctx context.Context, address common.Address, hash []byte, signature []byte) (bool, error) {
+	if len(hash) != common.HashLength {
+		return false, fmt.Errorf("hash is required to be exactly %d bytes (%d)", common.HashLength, len(hash))
+	}
+
+	code, err := v.caller.CodeAt(ctx, address, nil)
+	if err != nil {
+		return false, fmt.Errorf("can not get code of address: %s, err: %w", address, err)
+	}
+
+	if len(code) == 0 {
+		return verifyHashSignature(address, hash, signature)
+	}
+
+	if bytes.HasPrefix(code, delegationPrefix) {
+		if valid, err := verifyHashSignature(address, hash, signature); err == nil && valid {
+			return true, nil
+		}
+	}
+
+	return v.isValidSignature(ctx, address, common.BytesToHash(hash), signature)
+}
+
+// VerifyMessage verifies a signature of message with the "\x19Ethereum Signed Message:\n" prefix like VerifyHash.
+func (v *SignatureVerifier) VerifyMessage(ctx context.Context, address common.Address, message []byte, signature []byte) (bool, error) {
+	return v.VerifyHash(ctx, address, toEthSignedMessageHash(message), signature)
+}
+
+// VerifyTypedData verifies a signature of the EIP-712 hash of typedData like VerifyHash.
+func (v *SignatureVerifier) VerifyTypedData(ctx context.Context, address common.Address, typedData apitypes.TypedData, signature []byte) (bool, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return false, err
+	}
+
+	return v.VerifyHash(ctx, address, hash, signature)
+}
+
+func (v *SignatureVerifier) isValidSignature(ctx context.Context, address common.Address, hash common.Hash, signature []byte) (bool, error) {
+	data, err := eip1271Abi.Pack("isValidSignature", hash, signature)
+	if err != nil {
+		return false, err
+	}
+
+	output, err := v.caller.CallContract(ctx, ethereum.CallMsg{To: &address, Data: data}, nil)
+	if isRevertError(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("can not call isValidSignature of address: %s, err: %w", address, err)
+	}
+
+	return len(output) >= 4 && bytes.Equal(output[:4], EIP1271MagicValue[:]), nil
+}
+
+func isRevertError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == 3 {
+		return true
+	}
+
+	return strings.Contains(err.Error(), "execution reverted")
+}
+
+// SafeMessageHash returns the hash the owners of the Safe at safe sign so that the Safe's isValidSignature (through
+// its CompatibilityFallbackHandler) accepts hash: the EIP-712 hash of SafeMessage(bytes message) with
+// message = abi.encode(hash).
+func SafeMessageHash(safe common.Address, chainId *big.Int, hash common.Hash) (common.Hash, error) {
+	typedData := SafeTransactionTypedData(safe, chainId, SafeTransaction{})
+	typedData.Types = apitypes.Types{
+		"EIP712Domain": typedData.Types["EIP712Domain"],
+		"SafeMessage":  {{Name: "message", Type: "bytes"}},
+	}
+	typedData.PrimaryType = "SafeMessage"
+	typedData.Message = apitypes.TypedDataMessage{"message": hexutil.Encode(hash.Bytes())}
+
+	safeMessageHash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return common.BytesToHash(safeMessageHash), nil
+}
+
+// SignMessageHash signs hash on behalf of the Safe with every owner and returns the signature its EIP-1271
+// isValidSignature accepts for hash.
+func (s *SafeSigner) SignMessageHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	safeMessageHash, err := SafeMessageHash(s.safe, s.chainId, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures, err := s.signHash(ctx, safeMessageHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return JoinSafeSignatures(signatures...)
+}
@@ -0,0 +1,177 @@
+package kmswallet_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+// fakeContractCaller serves contract code and answers isValidSignature calls with isValidSignature.
+type fakeContractCaller struct {
+	code             map[common.Address][]byte
+	isValidSignature func(hash common.Hash, signature []byte) bool
+	callErr          error
+	calls            int
+}
+
+func (f *fakeContractCaller) CodeAt(_ context.Context, account common.Address, _ *big.Int) ([]byte, error) {
+	return f.code[account], nil
+}
+
+func (f *fakeContractCaller) CallContract(_ context.Context, call ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	f.calls++
+	if f.callErr != nil {
+		return nil, f.callErr
+	}
+
+	hash := common.BytesToHash(call.Data[4:36])
+	signatureLength := new(big.Int).SetBytes(call.Data[68:100]).Int64()
+	signature := call.Data[100 : 100+signatureLength]
+
+	output := make([]byte, 32)
+	if f.isValidSignature(hash, signature) {
+		copy(output, kmswallet.EIP1271MagicValue[:])
+	}
+
+	return output, nil
+}
+
+func TestSignatureVerifier_VerifyHash_Should_Use_Ecrecover_For_EOA(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	caller := &fakeContractCaller{}
+	verifier := kmswallet.NewSignatureVerifier(caller)
+	hash := crypto.Keccak256([]byte("payload"))
+	signature, _ := provider.SignHash(context.Background(), keyId, hash)
+
+	// when
+	valid, err := verifier.VerifyHash(context.Background(), client.Address(keyId), hash, signature)
+	otherSigner, errOther := verifier.VerifyHash(context.Background(), common.HexToAddress("0x01"), hash, signature)
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	assert.NoError(t, errOther)
+	assert.False(t, otherSigner)
+	assert.Zero(t, caller.calls)
+}
+
+func TestSignatureVerifier_VerifyHash_Should_Call_IsValidSignature_For_Contract(t *testing.T) {
+	// given
+	account := common.HexToAddress("0x9406Cc6185a346906296840746125a0E44976454")
+	hash := crypto.Keccak256Hash([]byte("payload"))
+	caller := &fakeContractCaller{
+		code: map[common.Address][]byte{account: {0x60, 0x80}},
+		isValidSignature: func(calledHash common.Hash, signature []byte) bool {
+			return calledHash == hash && string(signature) == "contract signature"
+		},
+	}
+	verifier := kmswallet.NewSignatureVerifier(caller)
+
+	// when
+	valid, err := verifier.VerifyHash(context.Background(), account, hash.Bytes(), []byte("contract signature"))
+	invalid, errInvalid := verifier.VerifyHash(context.Background(), account, hash.Bytes(), []byte("other signature"))
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	assert.NoError(t, errInvalid)
+	assert.False(t, invalid)
+}
+
+func TestSignatureVerifier_VerifyHash_When_Contract_Reverts(t *testing.T) {
+	// given
+	account := common.HexToAddress("0x9406Cc6185a346906296840746125a0E44976454")
+	caller := &fakeContractCaller{
+		code:    map[common.Address][]byte{account: {0x60, 0x80}},
+		callErr: errors.New("execution reverted"),
+	}
+	verifier := kmswallet.NewSignatureVerifier(caller)
+
+	// when
+	valid, err := verifier.VerifyHash(context.Background(), account, crypto.Keccak256(nil), []byte{0x01})
+
+	// then
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestSignatureVerifier_VerifyHash_When_Call_Fails(t *testing.T) {
+	// given
+	account := common.HexToAddress("0x9406Cc6185a346906296840746125a0E44976454")
+	caller := &fakeContractCaller{
+		code:    map[common.Address][]byte{account: {0x60, 0x80}},
+		callErr: errors.New("connection refused"),
+	}
+	verifier := kmswallet.NewSignatureVerifier(caller)
+
+	// when
+	valid, err := verifier.VerifyHash(context.Background(), account, crypto.Keccak256(nil), []byte{0x01})
+
+	// then
+	assert.ErrorContains(t, err, "connection refused")
+	assert.False(t, valid)
+}
+
+func TestSignatureVerifier_VerifyHash_Should_Accept_Delegated_EOA_Signature(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	address := client.Address(keyId)
+	caller := &fakeContractCaller{
+		code: map[common.Address][]byte{address: append([]byte{0xef, 0x01, 0x00}, common.HexToAddress("0x02").Bytes()...)},
+	}
+	verifier := kmswallet.NewSignatureVerifier(caller)
+	hash := crypto.Keccak256([]byte("payload"))
+	signature, _ := kmswallet.NewProvider(client, nil).SignHash(context.Background(), keyId, hash)
+
+	// when
+	valid, err := verifier.VerifyHash(context.Background(), address, hash, signature)
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	assert.Zero(t, caller.calls)
+}
+
+func TestSafeSigner_SignMessageHash(t *testing.T) {
+	// given
+	chainId := big.NewInt(1)
+	client, keyId := newTestKMSClient(t)
+	owner := client.Address(keyId)
+	safeSigner := kmswallet.NewSafeSigner(testSafeAddress, chainId, kmswallet.WalletKey{Provider: kmswallet.NewProvider(client, nil), KeyId: keyId})
+	hash := crypto.Keccak256Hash([]byte("payload"))
+
+	domainSeparator := crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(uint256 chainId,address verifyingContract)")),
+		word(chainId),
+		common.LeftPadBytes(testSafeAddress.Bytes(), 32),
+	)
+	safeMessageHash := eip712Digest(domainSeparator, crypto.Keccak256(crypto.Keccak256([]byte("SafeMessage(bytes message)")), crypto.Keccak256(hash.Bytes())))
+
+	caller := &fakeContractCaller{
+		code: map[common.Address][]byte{testSafeAddress: {0x60, 0x80}},
+		isValidSignature: func(calledHash common.Hash, signature []byte) bool {
+			signer, err := kmswallet.RecoverAddress(safeMessageHash, signature)
+			return calledHash == hash && err == nil && signer == owner
+		},
+	}
+
+	// when
+	computedHash, err := kmswallet.SafeMessageHash(testSafeAddress, chainId, hash)
+	signature, signErr := safeSigner.SignMessageHash(context.Background(), hash)
+	valid, verifyErr := kmswallet.NewSignatureVerifier(caller).VerifyHash(context.Background(), testSafeAddress, hash.Bytes(), signature)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, common.BytesToHash(safeMessageHash), computedHash)
+	assert.NoError(t, signErr)
+	assert.NoError(t, verifyErr)
+	assert.True(t, valid)
+}
@@ -0,0 +1,130 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"sync"
+)
+
+type fakeBackend struct {
+	bind.ContractBackend
+	mu                sync.Mutex
+	pendingNonce      uint64
+	pendingNonceCalls int
+	headNumber        int64
+	baseFee           *big.Int
+	gasTipCap         *big.Int
+	gasPrice          *big.Int
+	estimatedGas      uint64
+	sendErr           error
+	sentTxs           []*types.Transaction
+	receipts          map[common.Hash]*types.Receipt
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		headNumber:   100,
+		baseFee:      big.NewInt(10_000_000_000),
+		gasTipCap:    big.NewInt(1_000_000_000),
+		gasPrice:     big.NewInt(20_000_000_000),
+		estimatedGas: 21000,
+		receipts:     map[common.Hash]*types.Receipt{},
+	}
+}
+
+func (b *fakeBackend) PendingNonceAt(_ context.Context, _ common.Address) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pendingNonceCalls++
+	return b.pendingNonce, nil
+}
+
+func (b *fakeBackend) HeaderByNumber(_ context.Context, _ *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &types.Header{Number: big.NewInt(b.headNumber), BaseFee: b.baseFee}, nil
+}
+
+func (b *fakeBackend) SuggestGasTipCap(_ context.Context) (*big.Int, error) {
+	return b.gasTipCap, nil
+}
+
+func (b *fakeBackend) SuggestGasPrice(_ context.Context) (*big.Int, error) {
+	return b.gasPrice, nil
+}
+
+func (b *fakeBackend) EstimateGas(_ context.Context, _ ethereum.CallMsg) (uint64, error) {
+	return b.estimatedGas, nil
+}
+
+func (b *fakeBackend) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sendErr != nil {
+		return b.sendErr
+	}
+
+	b.sentTxs = append(b.sentTxs, tx)
+	return nil
+}
+
+func (b *fakeBackend) TransactionReceipt(_ context.Context, txHash common.Hash) (*types.Receipt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	receipt, ok := b.receipts[txHash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+
+	return receipt, nil
+}
+
+func (b *fakeBackend) setPendingNonce(nonce uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pendingNonce = nonce
+}
+
+func (b *fakeBackend) mine(txHash common.Hash, status uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.headNumber++
+	b.receipts[txHash] = &types.Receipt{
+		TxHash:      txHash,
+		Status:      status,
+		BlockNumber: big.NewInt(b.headNumber),
+	}
+}
+
+func (b *fakeBackend) advance(blocks int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.headNumber += blocks
+}
+
+func (b *fakeBackend) lastSentTx() *types.Transaction {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.sentTxs) == 0 {
+		return nil
+	}
+
+	return b.sentTxs[len(b.sentTxs)-1]
+}
+
+func (b *fakeBackend) TransactionByHash(_ context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, tx := range b.sentTxs {
+		if tx.Hash() == txHash {
+			_, mined := b.receipts[txHash]
+			return tx, !mined, nil
+		}
+	}
+
+	return nil, false, ethereum.NotFound
+}
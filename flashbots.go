@@ -0,0 +1,132 @@
+package kmswallet
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ether_types "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+)
+
+const FlashbotsSignatureHeader = "X-Flashbots-Signature"
+
+// SignFlashbotsPayload returns the X-Flashbots-Signature header value of a relay request body:
+// "address:signature", where the signature is SignMessage of the hex encoded keccak of body.
+func (c *provider) SignFlashbotsPayload(ctx context.Context, keyId string, body []byte) (string, error) {
+	wallet, err := c.GetWallet(ctx, keyId)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := c.SignMessage(ctx, keyId, []byte(hexutil.Encode(crypto.Keccak256(body))))
+	if err != nil {
+		return "", err
+	}
+
+	return wallet.Address + ":" + hexutil.Encode(signature), nil
+}
+
+// FlashbotsBundle is the params object of an eth_sendBundle request.
+type FlashbotsBundle struct {
+	Txs          []hexutil.Bytes `json:"txs"`
+	BlockNumber  hexutil.Uint64  `json:"blockNumber"`
+	MinTimestamp uint64          `json:"minTimestamp,omitempty"`
+	MaxTimestamp uint64          `json:"maxTimestamp,omitempty"`
+}
+
+// BundleBuilder signs an ordered sequence of transactions from KMS wallets. The transactions of each wallet get
+// sequential nonces, starting from its pending nonce.
+type BundleBuilder struct {
+	provider Provider
+	backend  bind.ContractBackend
+	chainId  *big.Int
+	nonces   map[common.Address]uint64
+	txs      []*ether_types.Transaction
+}
+
+func NewBundleBuilder(provider Provider, backend bind.ContractBackend, chainId *big.Int) *BundleBuilder {
+	return &BundleBuilder{
+		provider: provider,
+		backend:  backend,
+		chainId:  chainId,
+		nonces:   map[common.Address]uint64{},
+	}
+}
+
+// Add sets the wallet's next nonce on txData, signs it and appends it to the bundle.
+// txData can be a LegacyTx, AccessListTx, DynamicFeeTx or BlobTx.
+func (b *BundleBuilder) Add(ctx context.Context, keyId string, txData ether_types.TxData) (*ether_types.Transaction, error) {
+	wallet, err := b.provider.GetWallet(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	from := common.HexToAddress(wallet.Address)
+	nonce, ok := b.nonces[from]
+	if !ok {
+		if nonce, err = b.backend.PendingNonceAt(ctx, from); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = setTxDataNonce(txData, nonce); err != nil {
+		return nil, err
+	}
+
+	signedTx, err := b.provider.SignTransactionForChain(ctx, keyId, b.chainId, ether_types.NewTx(txData))
+	if err != nil {
+		return nil, err
+	}
+
+	b.nonces[from] = nonce + 1
+	b.txs = append(b.txs, signedTx)
+	return signedTx, nil
+}
+
+// AddSigned appends an already signed transaction, e.g. the transaction a backrun follows.
+func (b *BundleBuilder) AddSigned(tx *ether_types.Transaction) {
+	b.txs = append(b.txs, tx)
+}
+
+func (b *BundleBuilder) Transactions() []*ether_types.Transaction {
+	return append([]*ether_types.Transaction{}, b.txs...)
+}
+
+// Bundle returns the eth_sendBundle params of the transactions targeting blockNumber.
+func (b *BundleBuilder) Bundle(blockNumber uint64) (*FlashbotsBundle, error) {
+	bundle := &FlashbotsBundle{
+		Txs:         make([]hexutil.Bytes, len(b.txs)),
+		BlockNumber: hexutil.Uint64(blockNumber),
+	}
+
+	for i, tx := range b.txs {
+		rawTx, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		bundle.Txs[i] = rawTx
+	}
+
+	return bundle, nil
+}
+
+func setTxDataNonce(txData ether_types.TxData, nonce uint64) error {
+	switch data := txData.(type) {
+	case *ether_types.LegacyTx:
+		data.Nonce = nonce
+	case *ether_types.AccessListTx:
+		data.Nonce = nonce
+	case *ether_types.DynamicFeeTx:
+		data.Nonce = nonce
+	case *ether_types.BlobTx:
+		data.Nonce = nonce
+	default:
+		return fmt.Errorf("unsupported bundle transaction type: %T", txData)
+	}
+
+	return nil
+}
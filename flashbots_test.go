@@ -0,0 +1,99 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestSignFlashbotsPayload(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_sendBundle","params":[]}`)
+
+	// when
+	header, err := provider.SignFlashbotsPayload(context.Background(), keyId, body)
+
+	// then
+	assert.NoError(t, err)
+	parts := strings.Split(header, ":")
+	assert.Len(t, parts, 2)
+	assert.Equal(t, client.Address(keyId).Hex(), parts[0])
+
+	signature, err := hexutil.Decode(parts[1])
+	assert.NoError(t, err)
+	signer, err := kmswallet.RecoverAddress(accounts.TextHash([]byte(crypto.Keccak256Hash(body).Hex())), signature)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), signer)
+}
+
+func TestBundleBuilder_Should_Assign_Sequential_Nonces_Per_Wallet(t *testing.T) {
+	// given
+	chainId := big.NewInt(1)
+	backend := newFakeBackend()
+	backend.setPendingNonce(7)
+	firstClient, firstKeyId := newTestKMSClient(t)
+	secondClient, secondKeyId := newTestKMSClient(t)
+	first, second := kmswallet.NewProvider(firstClient, nil), kmswallet.NewProvider(secondClient, nil)
+	to := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+	newTxData := func() *types.DynamicFeeTx {
+		return &types.DynamicFeeTx{ChainID: chainId, GasTipCap: big.NewInt(2e9), GasFeeCap: big.NewInt(50e9), Gas: 21000, To: &to, Value: big.NewInt(1)}
+	}
+
+	firstBuilder := kmswallet.NewBundleBuilder(first, backend, chainId)
+	secondBuilder := kmswallet.NewBundleBuilder(second, backend, chainId)
+
+	// when
+	_, err1 := firstBuilder.Add(context.Background(), firstKeyId, newTxData())
+	_, err2 := firstBuilder.Add(context.Background(), firstKeyId, newTxData())
+	_, err3 := firstBuilder.Add(context.Background(), firstKeyId, &types.LegacyTx{GasPrice: big.NewInt(50e9), Gas: 21000, To: &to})
+	secondTx, err4 := secondBuilder.Add(context.Background(), secondKeyId, newTxData())
+	firstBuilder.AddSigned(secondTx)
+	bundle, err := firstBuilder.Bundle(19_000_000)
+
+	// then
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NoError(t, err3)
+	assert.NoError(t, err4)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, backend.pendingNonceCalls)
+	assert.Equal(t, hexutil.Uint64(19_000_000), bundle.BlockNumber)
+	assert.Len(t, bundle.Txs, 4)
+
+	expected := []struct {
+		from  string
+		nonce uint64
+	}{{firstClient.Address(firstKeyId).Hex(), 7}, {firstClient.Address(firstKeyId).Hex(), 8}, {firstClient.Address(firstKeyId).Hex(), 9}, {secondClient.Address(secondKeyId).Hex(), 7}}
+	for i, rawTx := range bundle.Txs {
+		tx := new(types.Transaction)
+		assert.NoError(t, tx.UnmarshalBinary(rawTx))
+		from, err := types.Sender(types.LatestSignerForChainID(chainId), tx)
+		assert.NoError(t, err)
+		assert.Equal(t, expected[i].from, from.String())
+		assert.Equal(t, expected[i].nonce, tx.Nonce())
+	}
+}
+
+func TestBundleBuilder_Add_Should_Reject_Unsupported_Transaction_Type(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	builder := kmswallet.NewBundleBuilder(kmswallet.NewProvider(client, nil), newFakeBackend(), big.NewInt(1))
+
+	// when
+	tx, err := builder.Add(context.Background(), keyId, nil)
+
+	// then
+	assert.ErrorContains(t, err, "unsupported bundle transaction type")
+	assert.Nil(t, tx)
+	assert.Empty(t, builder.Transactions())
+}
@@ -0,0 +1,197 @@
+// Package ethrpc holds the JSON-RPC plumbing shared by the HTTP signing servers.
+package ethrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/node"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+
+	maxRequestSize = 5 * 1024 * 1024
+)
+
+// acceptedContentTypes are the JSON content types geth accepts. Browsers only send them cross-origin after a CORS
+// preflight, so requiring one keeps pages from posting "simple" requests to the servers.
+var acceptedContentTypes = map[string]bool{
+	"application/json":        true,
+	"application/json-rpc":    true,
+	"application/jsonrequest": true,
+}
+
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// HandlerFunc handles a single request. Errors that are not an *Error are returned with CodeServerError.
+type HandlerFunc func(ctx context.Context, request *Request) (interface{}, error)
+
+// InvalidParams returns a CodeInvalidParams error.
+func InvalidParams(format string, args ...interface{}) *Error {
+	return &Error{Code: CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// MethodNotFound returns the CodeMethodNotFound error of method.
+func MethodNotFound(method string) *Error {
+	return &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("the method %s does not exist/is not available", method)}
+}
+
+// ParseParams decodes the positional params of request into targets. Missing trailing params leave their targets
+// unchanged.
+func ParseParams(request *Request, targets ...interface{}) error {
+	var params []json.RawMessage
+	if len(request.Params) > 0 {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return InvalidParams("invalid params: %v", err)
+		}
+	}
+
+	if len(params) > len(targets) {
+		return InvalidParams("too many arguments, want at most %d", len(targets))
+	}
+
+	for i, param := range params {
+		if err := json.Unmarshal(param, targets[i]); err != nil {
+			return InvalidParams("invalid argument %d: %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Serve reads a single or batch JSON-RPC request from r, handles every request with handle and writes the responses.
+// Requests without a JSON Content-Type are refused with 415.
+func Serve(w http.ResponseWriter, r *http.Request, handle HandlerFunc) {
+	if !CheckContentType(w, r) {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	var response interface{}
+	if len(body) > 0 && body[0] == '[' {
+		var requests []*Request
+		if err = json.Unmarshal(body, &requests); err != nil {
+			response = errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()})
+		} else {
+			responses := make([]*Response, len(requests))
+			for i, request := range requests {
+				responses[i] = serveRequest(r.Context(), request, handle)
+			}
+
+			response = responses
+		}
+	} else {
+		var request Request
+		if err = json.Unmarshal(body, &request); err != nil {
+			response = errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()})
+		} else {
+			response = serveRequest(r.Context(), &request, handle)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// CheckContentType writes a 415 response and returns false unless r has a JSON Content-Type.
+func CheckContentType(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !acceptedContentTypes[mediaType] {
+		http.Error(w, "invalid content type, only application/json is supported", http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	return true
+}
+
+// HTTPHandler wraps handler with geth's HTTP stack: requests are refused unless their Host is in vhosts, and CORS
+// headers are only sent to the origins in cors. Both are comma separated lists, "*" allows any; an empty cors sends
+// no CORS headers, so browsers refuse cross-origin JSON requests.
+func HTTPHandler(handler http.Handler, cors string, vhosts string) http.Handler {
+	return node.NewHTTPHandlerStack(handler, splitList(cors), splitList(vhosts), nil)
+}
+
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func serveRequest(ctx context.Context, request *Request, handle HandlerFunc) *Response {
+	if request.Method == "" {
+		return errorResponse(request.Id, &Error{Code: CodeInvalidRequest, Message: "method is required"})
+	}
+
+	result, err := handle(ctx, request)
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeServerError, Message: err.Error()}
+		}
+
+		return errorResponse(request.Id, rpcErr)
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(request.Id, &Error{Code: CodeServerError, Message: err.Error()})
+	}
+
+	return &Response{JSONRPC: "2.0", Id: responseId(request.Id), Result: encoded}
+}
+
+func errorResponse(id json.RawMessage, err *Error) *Response {
+	return &Response{JSONRPC: "2.0", Id: responseId(id), Error: err}
+}
+
+func responseId(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+
+	return id
+}
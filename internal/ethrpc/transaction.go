@@ -0,0 +1,104 @@
+package ethrpc
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// TransactionArgs are the transaction arguments of eth_sendTransaction and eth_signTransaction.
+type TransactionArgs struct {
+	From                 *common.Address   `json:"from"`
+	To                   *common.Address   `json:"to"`
+	Gas                  *hexutil.Uint64   `json:"gas"`
+	GasPrice             *hexutil.Big      `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big      `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big      `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big      `json:"value"`
+	Nonce                *hexutil.Uint64   `json:"nonce"`
+	Data                 *hexutil.Bytes    `json:"data"`
+	Input                *hexutil.Bytes    `json:"input"`
+	AccessList           *types.AccessList `json:"accessList,omitempty"`
+	ChainId              *hexutil.Big      `json:"chainId,omitempty"`
+}
+
+// CallData returns input, or data when input is not set.
+func (args *TransactionArgs) CallData() []byte {
+	if args.Input != nil {
+		return *args.Input
+	}
+
+	if args.Data != nil {
+		return *args.Data
+	}
+
+	return nil
+}
+
+// ToTransaction returns the unsigned transaction of args for chainId. From, gas, nonce and either the gas price or
+// the EIP-1559 fees are required; a chainId in args must match chainId.
+func (args *TransactionArgs) ToTransaction(chainId *big.Int) (*types.Transaction, error) {
+	switch {
+	case args.From == nil:
+		return nil, InvalidParams("from is required")
+	case args.Gas == nil:
+		return nil, InvalidParams("gas is required")
+	case args.Nonce == nil:
+		return nil, InvalidParams("nonce is required")
+	case args.ChainId != nil && args.ChainId.ToInt().Cmp(chainId) != 0:
+		return nil, InvalidParams("chainId %s does not match the chain id of the signer %s", args.ChainId.ToInt(), chainId)
+	case args.GasPrice != nil && (args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil):
+		return nil, InvalidParams("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+	}
+
+	value := new(big.Int)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+
+	if args.MaxFeePerGas != nil && args.MaxPriorityFeePerGas != nil {
+		var accessList types.AccessList
+		if args.AccessList != nil {
+			accessList = *args.AccessList
+		}
+
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    chainId,
+			Nonce:      uint64(*args.Nonce),
+			GasTipCap:  args.MaxPriorityFeePerGas.ToInt(),
+			GasFeeCap:  args.MaxFeePerGas.ToInt(),
+			Gas:        uint64(*args.Gas),
+			To:         args.To,
+			Value:      value,
+			Data:       args.CallData(),
+			AccessList: accessList,
+		}), nil
+	}
+
+	if args.GasPrice == nil {
+		return nil, InvalidParams("gasPrice or maxFeePerGas and maxPriorityFeePerGas are required")
+	}
+
+	if args.AccessList != nil {
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    chainId,
+			Nonce:      uint64(*args.Nonce),
+			GasPrice:   args.GasPrice.ToInt(),
+			Gas:        uint64(*args.Gas),
+			To:         args.To,
+			Value:      value,
+			Data:       args.CallData(),
+			AccessList: *args.AccessList,
+		}), nil
+	}
+
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    uint64(*args.Nonce),
+		GasPrice: args.GasPrice.ToInt(),
+		Gas:      uint64(*args.Gas),
+		To:       args.To,
+		Value:    value,
+		Data:     args.CallData(),
+	}), nil
+}
@@ -0,0 +1,525 @@
+// Package kmstest provides an in-memory KMSClient for testing code built on the wallet provider without AWS.
+package kmstest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"fmt"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const aliasPrefix = "alias/"
+
+type key struct {
+	privateKey *ecdsa.PrivateKey
+	metadata   types.KeyMetadata
+	tags       []types.Tag
+}
+
+// Client is an in-memory KMSClient. Keys are secp256k1 keys held locally, and public keys and signatures are DER
+// encoded like KMS returns them. Unknown keys and aliases fail with NotFoundException, and disabled keys and keys
+// pending deletion refuse to sign or return their public key. It is safe for concurrent use.
+type Client struct {
+	mu        sync.Mutex
+	keys      map[string]*key
+	aliases   map[string]string
+	nextId    int
+	signHook  func(ctx context.Context, digest []byte) error
+	signCalls int
+	highS     bool
+}
+
+func NewClient() *Client {
+	return &Client{
+		keys:    map[string]*key{},
+		aliases: map[string]string{},
+	}
+}
+
+// AddKey adds an enabled key with a random private key and returns its keyId. It fails t when the key can not be
+// generated.
+func (c *Client) AddKey(t testing.TB) string {
+	t.Helper()
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("can not generate key: %v", err)
+	}
+
+	return c.AddPrivateKey(privateKey)
+}
+
+// AddPrivateKey adds an enabled key with the given private key and returns its keyId.
+func (c *Client) AddPrivateKey(privateKey *ecdsa.PrivateKey) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.addKey(privateKey, types.KeyMetadata{})
+}
+
+// AddAlias points alias, with or without the "alias/" prefix, to keyId. Existing aliases are repointed.
+func (c *Client) AddAlias(alias string, keyId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.aliases[prefixAlias(alias)] = keyId
+}
+
+// Address returns the Ethereum address of keyId.
+func (c *Client) Address(keyId string) common.Address {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return crypto.PubkeyToAddress(c.keys[keyId].privateKey.PublicKey)
+}
+
+// SetSignHook runs hook before every Sign call, with the context and digest of the call. Sign fails with the error
+// hook returns.
+func (c *Client) SetSignHook(hook func(ctx context.Context, digest []byte) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.signHook = hook
+}
+
+// SetHighS makes Sign return the high-S form of its signatures, which KMS returns for about half of them.
+func (c *Client) SetHighS(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.highS = enabled
+}
+
+// SignCalls returns the number of Sign calls, including failed ones.
+func (c *Client) SignCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.signCalls
+}
+
+// Tags returns the tags of keyId.
+func (c *Client) Tags(keyId string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tags := map[string]string{}
+	for _, tag := range c.keys[keyId].tags {
+		tags[aws.ToString(tag.TagKey)] = aws.ToString(tag.TagValue)
+	}
+
+	return tags
+}
+
+func (c *Client) CreateKey(_ context.Context, params *kms.CreateKeyInput, _ ...func(*kms.Options)) (*kms.CreateKeyOutput, error) {
+	if params.KeySpec != types.KeySpecEccSecgP256k1 {
+		return nil, &types.UnsupportedOperationException{Message: aws.String(fmt.Sprintf("unsupported key spec: %s", params.KeySpec))}
+	}
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keyId := c.addKey(privateKey, types.KeyMetadata{
+		Description: params.Description,
+		MultiRegion: params.MultiRegion,
+		Origin:      params.Origin,
+	})
+	c.keys[keyId].tags = append(c.keys[keyId].tags, params.Tags...)
+
+	metadata := c.keys[keyId].metadata
+	return &kms.CreateKeyOutput{KeyMetadata: &metadata}, nil
+}
+
+func (c *Client) CreateAlias(_ context.Context, params *kms.CreateAliasInput, _ ...func(*kms.Options)) (*kms.CreateAliasOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	aliasName := aws.ToString(params.AliasName)
+	if !strings.HasPrefix(aliasName, aliasPrefix) {
+		return nil, &types.InvalidAliasNameException{Message: aws.String(fmt.Sprintf("alias name is required to start with %s", aliasPrefix))}
+	}
+
+	if _, ok := c.aliases[aliasName]; ok {
+		return nil, &types.AlreadyExistsException{Message: aws.String(fmt.Sprintf("alias %s already exists", aliasName))}
+	}
+
+	k, err := c.key(aws.ToString(params.TargetKeyId))
+	if err != nil {
+		return nil, err
+	}
+
+	c.aliases[aliasName] = aws.ToString(k.metadata.KeyId)
+	return &kms.CreateAliasOutput{}, nil
+}
+
+func (c *Client) TagResource(_ context.Context, params *kms.TagResourceInput, _ ...func(*kms.Options)) (*kms.TagResourceOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k, err := c.key(aws.ToString(params.KeyId))
+	if err != nil {
+		return nil, err
+	}
+
+	k.tags = append(k.tags, params.Tags...)
+	return &kms.TagResourceOutput{}, nil
+}
+
+func (c *Client) DescribeKey(_ context.Context, params *kms.DescribeKeyInput, _ ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k, err := c.key(aws.ToString(params.KeyId))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := k.metadata
+	return &kms.DescribeKeyOutput{KeyMetadata: &metadata}, nil
+}
+
+func (c *Client) GetPublicKey(_ context.Context, params *kms.GetPublicKeyInput, _ ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k, err := c.enabledKey(aws.ToString(params.KeyId))
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := asn1.Marshal(struct {
+		EcPublicKeyInfo struct {
+			Algorithm  asn1.ObjectIdentifier
+			Parameters asn1.ObjectIdentifier
+		}
+		PublicKey asn1.BitString
+	}{
+		EcPublicKeyInfo: struct {
+			Algorithm  asn1.ObjectIdentifier
+			Parameters asn1.ObjectIdentifier
+		}{
+			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1},
+			Parameters: asn1.ObjectIdentifier{1, 3, 132, 0, 10},
+		},
+		PublicKey: asn1.BitString{Bytes: crypto.FromECDSAPub(&k.privateKey.PublicKey), BitLength: 520},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.GetPublicKeyOutput{
+		KeyId:     k.metadata.KeyId,
+		KeySpec:   types.KeySpecEccSecgP256k1,
+		KeyUsage:  types.KeyUsageTypeSignVerify,
+		PublicKey: publicKey,
+	}, nil
+}
+
+func (c *Client) Sign(ctx context.Context, params *kms.SignInput, _ ...func(*kms.Options)) (*kms.SignOutput, error) {
+	c.mu.Lock()
+	c.signCalls++
+	signHook := c.signHook
+	c.mu.Unlock()
+
+	if signHook != nil {
+		if err := signHook(ctx, params.Message); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k, err := c.enabledKey(aws.ToString(params.KeyId))
+	if err != nil {
+		return nil, err
+	}
+
+	if params.MessageType != types.MessageTypeDigest || len(params.Message) != 32 {
+		return nil, &smithy.GenericAPIError{Code: "ValidationException", Message: "only 32 bytes digests are supported"}
+	}
+
+	signature, err := crypto.Sign(params.Message, k.privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(big.Int).SetBytes(signature[32:64])
+	if c.highS {
+		s.Sub(crypto.S256().Params().N, s)
+	}
+
+	der, err := asn1.Marshal(struct {
+		R *big.Int
+		S *big.Int
+	}{
+		R: new(big.Int).SetBytes(signature[:32]),
+		S: s,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.SignOutput{
+		KeyId:            k.metadata.KeyId,
+		Signature:        der,
+		SigningAlgorithm: params.SigningAlgorithm,
+	}, nil
+}
+
+func (c *Client) EnableKey(_ context.Context, params *kms.EnableKeyInput, _ ...func(*kms.Options)) (*kms.EnableKeyOutput, error) {
+	return &kms.EnableKeyOutput{}, c.setEnabled(aws.ToString(params.KeyId), true)
+}
+
+func (c *Client) DisableKey(_ context.Context, params *kms.DisableKeyInput, _ ...func(*kms.Options)) (*kms.DisableKeyOutput, error) {
+	return &kms.DisableKeyOutput{}, c.setEnabled(aws.ToString(params.KeyId), false)
+}
+
+func (c *Client) Verify(_ context.Context, params *kms.VerifyInput, _ ...func(*kms.Options)) (*kms.VerifyOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k, err := c.enabledKey(aws.ToString(params.KeyId))
+	if err != nil {
+		return nil, err
+	}
+
+	var signature struct {
+		R *big.Int
+		S *big.Int
+	}
+
+	if _, err = asn1.Unmarshal(params.Signature, &signature); err != nil {
+		return nil, &types.KMSInvalidSignatureException{Message: aws.String(err.Error())}
+	}
+
+	if !ecdsa.Verify(&k.privateKey.PublicKey, params.Message, signature.R, signature.S) {
+		return nil, &types.KMSInvalidSignatureException{}
+	}
+
+	return &kms.VerifyOutput{KeyId: k.metadata.KeyId, SignatureValid: true}, nil
+}
+
+// ScheduleKeyDeletion puts the key in the PendingDeletion state. Keys are never actually deleted.
+func (c *Client) ScheduleKeyDeletion(_ context.Context, params *kms.ScheduleKeyDeletionInput, _ ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k, err := c.key(aws.ToString(params.KeyId))
+	if err != nil {
+		return nil, err
+	}
+
+	pendingWindowInDays := aws.ToInt32(params.PendingWindowInDays)
+	if params.PendingWindowInDays == nil {
+		pendingWindowInDays = 30
+	}
+
+	if pendingWindowInDays < 7 || pendingWindowInDays > 30 {
+		return nil, &smithy.GenericAPIError{Code: "ValidationException", Message: "pending window is required to be between 7 and 30 days"}
+	}
+
+	if k.metadata.KeyState == types.KeyStatePendingDeletion {
+		return nil, &types.KMSInvalidStateException{Message: aws.String(fmt.Sprintf("%s is pending deletion", aws.ToString(k.metadata.Arn)))}
+	}
+
+	k.metadata.Enabled = false
+	k.metadata.KeyState = types.KeyStatePendingDeletion
+	k.metadata.DeletionDate = aws.Time(time.Now().AddDate(0, 0, int(pendingWindowInDays)))
+	k.metadata.PendingDeletionWindowInDays = aws.Int32(pendingWindowInDays)
+
+	return &kms.ScheduleKeyDeletionOutput{
+		KeyId:               k.metadata.Arn,
+		KeyState:            k.metadata.KeyState,
+		DeletionDate:        k.metadata.DeletionDate,
+		PendingWindowInDays: k.metadata.PendingDeletionWindowInDays,
+	}, nil
+}
+
+// ListKeys lists the keys in the order they were added. Limit and Marker page through them like in KMS.
+func (c *Client) ListKeys(_ context.Context, params *kms.ListKeysInput, _ ...func(*kms.Options)) (*kms.ListKeysOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keyIds := make([]string, 0, len(c.keys))
+	for keyId := range c.keys {
+		keyIds = append(keyIds, keyId)
+	}
+
+	sort.Strings(keyIds)
+	start, end, nextMarker, err := page(len(keyIds), params.Limit, params.Marker)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &kms.ListKeysOutput{NextMarker: nextMarker, Truncated: nextMarker != nil}
+	for _, keyId := range keyIds[start:end] {
+		output.Keys = append(output.Keys, types.KeyListEntry{KeyId: aws.String(keyId), KeyArn: c.keys[keyId].metadata.Arn})
+	}
+
+	return output, nil
+}
+
+// ListAliases lists the aliases by name, of every key or only of the key in params. Limit and Marker page through
+// them like in KMS.
+func (c *Client) ListAliases(_ context.Context, params *kms.ListAliasesInput, _ ...func(*kms.Options)) (*kms.ListAliasesOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var keyId string
+	if params.KeyId != nil {
+		k, err := c.key(aws.ToString(params.KeyId))
+		if err != nil {
+			return nil, err
+		}
+
+		keyId = aws.ToString(k.metadata.KeyId)
+	}
+
+	var aliases []string
+	for alias, targetKeyId := range c.aliases {
+		if keyId == "" || targetKeyId == keyId {
+			aliases = append(aliases, alias)
+		}
+	}
+
+	sort.Strings(aliases)
+	start, end, nextMarker, err := page(len(aliases), params.Limit, params.Marker)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &kms.ListAliasesOutput{NextMarker: nextMarker, Truncated: nextMarker != nil}
+	for _, alias := range aliases[start:end] {
+		output.Aliases = append(output.Aliases, types.AliasListEntry{
+			AliasName:   aws.String(alias),
+			AliasArn:    aws.String("arn:aws:kms:us-east-1:000000000000:" + alias),
+			TargetKeyId: aws.String(c.aliases[alias]),
+		})
+	}
+
+	return output, nil
+}
+
+func (c *Client) addKey(privateKey *ecdsa.PrivateKey, metadata types.KeyMetadata) string {
+	c.nextId++
+	keyId := fmt.Sprintf("00000000-0000-4000-8000-%012d", c.nextId)
+
+	metadata.KeyId = aws.String(keyId)
+	metadata.Arn = aws.String("arn:aws:kms:us-east-1:000000000000:key/" + keyId)
+	metadata.CreationDate = aws.Time(time.Now())
+	metadata.Enabled = true
+	metadata.KeyState = types.KeyStateEnabled
+	metadata.KeySpec = types.KeySpecEccSecgP256k1
+	metadata.KeyUsage = types.KeyUsageTypeSignVerify
+	metadata.KeyManager = types.KeyManagerTypeCustomer
+	c.keys[keyId] = &key{privateKey: privateKey, metadata: metadata}
+	return keyId
+}
+
+func (c *Client) setEnabled(keyId string, enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k, err := c.key(keyId)
+	if err != nil {
+		return err
+	}
+
+	if k.metadata.KeyState == types.KeyStatePendingDeletion {
+		return &types.KMSInvalidStateException{Message: aws.String(fmt.Sprintf("%s is pending deletion", keyId))}
+	}
+
+	k.metadata.Enabled = enabled
+	k.metadata.KeyState = types.KeyStateDisabled
+	if enabled {
+		k.metadata.KeyState = types.KeyStateEnabled
+	}
+
+	return nil
+}
+
+func (c *Client) enabledKey(keyId string) (*key, error) {
+	k, err := c.key(keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k.metadata.KeyState {
+	case types.KeyStateEnabled:
+		return k, nil
+	case types.KeyStateDisabled:
+		return nil, &types.DisabledException{Message: aws.String(fmt.Sprintf("%s is disabled", aws.ToString(k.metadata.Arn)))}
+	default:
+		return nil, &types.KMSInvalidStateException{Message: aws.String(fmt.Sprintf("%s is %s", aws.ToString(k.metadata.Arn), k.metadata.KeyState))}
+	}
+}
+
+// key resolves a keyId, key ARN or alias name to its key.
+func (c *Client) key(keyId string) (*key, error) {
+	if strings.HasPrefix(keyId, aliasPrefix) {
+		aliasKeyId, ok := c.aliases[keyId]
+		if !ok {
+			return nil, &types.NotFoundException{Message: aws.String(fmt.Sprintf("alias %s is not found", keyId))}
+		}
+
+		keyId = aliasKeyId
+	}
+
+	keyId = keyId[strings.LastIndex(keyId, "/")+1:]
+	k, ok := c.keys[keyId]
+	if !ok {
+		return nil, &types.NotFoundException{Message: aws.String(fmt.Sprintf("key %s is not found", keyId))}
+	}
+
+	return k, nil
+}
+
+// page returns the bounds of the page of size items that starts at marker, and the marker of the next page.
+func page(size int, limit *int32, marker *string) (int, int, *string, error) {
+	start := 0
+	if marker != nil {
+		var err error
+		if start, err = strconv.Atoi(*marker); err != nil || start < 0 || start > size {
+			return 0, 0, nil, &types.InvalidMarkerException{Message: aws.String(fmt.Sprintf("invalid marker: %s", *marker))}
+		}
+	}
+
+	end := size
+	if limit != nil && start+int(*limit) < size {
+		end = start + int(*limit)
+		return start, end, aws.String(strconv.Itoa(end)), nil
+	}
+
+	return start, end, nil, nil
+}
+
+func prefixAlias(alias string) string {
+	if strings.HasPrefix(alias, aliasPrefix) {
+		return alias
+	}
+
+	return aliasPrefix + alias
+}
+
+var _ kmswallet.KMSClient = (*Client)(nil)
@@ -0,0 +1,22 @@
+package kmswallet
+
+import (
+	"context"
+	"log/slog"
+)
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// messageAttr returns the message to log: its plaintext when plaintext logging is allowed, otherwise only its length.
+func (c *provider) messageAttr(message []byte) slog.Attr {
+	if c.logPlaintext {
+		return slog.String("message", string(message))
+	}
+
+	return slog.Int("messageLength", len(message))
+}
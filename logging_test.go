@@ -0,0 +1,90 @@
+package kmswallet_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"log/slog"
+	"testing"
+)
+
+func newSignMessageMockClient(publicKeyBase64 string) *mockKMSClient {
+	mockClient := &mockKMSClient{}
+	publicKey, _ := base64.StdEncoding.DecodeString(publicKeyBase64)
+	signOutput, _ := base64.StdEncoding.DecodeString("MEUCIQD6wEcOzyjl8wr+OR8In54bVKgR5/ZogQQWiHPkqb70NQIgZZnGhIRlfGj9xexxACWQ4WZPB60swZP5DK6OjyEJIIc=")
+
+	mockClient.On("GetPublicKey", mock.Anything, mock.Anything, mock.Anything).Return(&kms.GetPublicKeyOutput{
+		PublicKey: publicKey,
+	}, nil)
+
+	mockClient.On("Sign", mock.Anything, mock.Anything, mock.Anything).Return(&kms.SignOutput{
+		Signature: signOutput,
+	}, nil)
+
+	return mockClient
+}
+
+func TestLogging_Should_Not_Log_Message_Plaintext_By_Default(t *testing.T) {
+	// given
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	mockClient := newSignMessageMockClient("MFYwEAYHKoZIzj0CAQYFK4EEAAoDQgAE1TWYYp+bySM6J3m99CxKhHZFgJqdwm5V6kGziuJ4kc7f0xORg5AqGRKbFNTSsmYTXNi2Z/cl298eyKTbmy+8aQ==")
+	provider := kmswallet.NewProvider(mockClient, nil, kmswallet.WithLogger(logger))
+
+	// when
+	_, err := provider.SignMessage(context.Background(), "keyId", []byte("Hello World!"))
+
+	// then
+	assert.NoError(t, err)
+	assert.Contains(t, buffer.String(), `"messageLength":12`)
+	assert.NotContains(t, buffer.String(), "Hello World!")
+}
+
+func TestLogging_Should_Log_Message_Plaintext_When_Allowed(t *testing.T) {
+	// given
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	mockClient := newSignMessageMockClient("MFYwEAYHKoZIzj0CAQYFK4EEAAoDQgAE1TWYYp+bySM6J3m99CxKhHZFgJqdwm5V6kGziuJ4kc7f0xORg5AqGRKbFNTSsmYTXNi2Z/cl298eyKTbmy+8aQ==")
+	provider := kmswallet.NewProvider(mockClient, nil, kmswallet.WithLogger(logger), kmswallet.WithPlaintextLogging())
+
+	// when
+	_, err := provider.SignMessage(context.Background(), "keyId", []byte("Hello World!"))
+
+	// then
+	assert.NoError(t, err)
+	assert.Contains(t, buffer.String(), `"message":"Hello World!"`)
+}
+
+func TestLogging_Should_Log_Context_When_Recover_Failed(t *testing.T) {
+	// given
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelError}))
+	mockClient := newSignMessageMockClient("MFYwEAYHKoZIzj0CAQYFK4EEAAoDQgAERtrxsFyn7UzP2OgzzJA6Y89p/2175fOwXeP33ACZgmdD2jJlQdypNM9CCDm3J6uqTrvYrO0hwF8p/k/Tf94DjA==")
+	provider := kmswallet.NewProvider(mockClient, nil, kmswallet.WithLogger(logger))
+
+	// when
+	_, err := provider.SignMessage(context.Background(), "keyId", []byte("Hello World!"))
+
+	// then
+	assert.Error(t, err)
+	assert.Contains(t, buffer.String(), `"msg":"can not reconstruct public key from sig"`)
+	assert.Contains(t, buffer.String(), `"keyId":"keyId"`)
+	assert.Contains(t, buffer.String(), `"digest":`)
+	assert.NotContains(t, buffer.String(), "Hello World!")
+}
+
+func TestWithLogger_Should_Ignore_Nil_Logger(t *testing.T) {
+	// given
+	mockClient := newSignMessageMockClient("MFYwEAYHKoZIzj0CAQYFK4EEAAoDQgAE1TWYYp+bySM6J3m99CxKhHZFgJqdwm5V6kGziuJ4kc7f0xORg5AqGRKbFNTSsmYTXNi2Z/cl298eyKTbmy+8aQ==")
+	provider := kmswallet.NewProvider(mockClient, nil, kmswallet.WithLogger(nil))
+
+	// when
+	_, err := provider.SignMessage(context.Background(), "keyId", []byte("Hello World!"))
+
+	// then
+	assert.NoError(t, err)
+}
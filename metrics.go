@@ -0,0 +1,149 @@
+package kmswallet
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go"
+	"time"
+)
+
+const (
+	KMSOutcomeSuccess  = "success"
+	KMSOutcomeError    = "error"
+	KMSOutcomeRejected = "rejected"
+)
+
+var policyRejectionErrorCodes = map[string]bool{
+	"AccessDeniedException":    true,
+	"DisabledException":        true,
+	"KMSInvalidStateException": true,
+}
+
+// Metrics receives the provider's instrumentation events. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveKMSCall is called once per KMS API call with the operation name (e.g. "Sign"),
+	// its outcome (KMSOutcomeSuccess, KMSOutcomeError or KMSOutcomeRejected) and its latency.
+	ObserveKMSCall(operation string, outcome string, duration time.Duration)
+	IncPublicKeyCacheHit()
+	IncPublicKeyCacheMiss()
+	// IncRecoveryIdRetry is called when the first recovery id candidate does not reconstruct the expected public key.
+	IncRecoveryIdRetry()
+	// IncPolicyRejection is called when a request is refused by a key policy, a key state, a registry wallet policy
+	// or an address pin, with the reason code.
+	IncPolicyRejection(operation string, reason string)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveKMSCall(string, string, time.Duration) {}
+func (noopMetrics) IncPublicKeyCacheHit()                        {}
+func (noopMetrics) IncPublicKeyCacheMiss()                       {}
+func (noopMetrics) IncRecoveryIdRetry()                          {}
+func (noopMetrics) IncPolicyRejection(string, string)            {}
+
+type instrumentedClient struct {
+	client  KMSClient
+	metrics Metrics
+}
+
+func (c *instrumentedClient) CreateKey(ctx context.Context, params *kms.CreateKeyInput, optFns ...func(*kms.Options)) (*kms.CreateKeyOutput, error) {
+	start := time.Now()
+	output, err := c.client.CreateKey(ctx, params, optFns...)
+	c.observe("CreateKey", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) CreateAlias(ctx context.Context, params *kms.CreateAliasInput, optFns ...func(*kms.Options)) (*kms.CreateAliasOutput, error) {
+	start := time.Now()
+	output, err := c.client.CreateAlias(ctx, params, optFns...)
+	c.observe("CreateAlias", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) TagResource(ctx context.Context, params *kms.TagResourceInput, optFns ...func(*kms.Options)) (*kms.TagResourceOutput, error) {
+	start := time.Now()
+	output, err := c.client.TagResource(ctx, params, optFns...)
+	c.observe("TagResource", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	start := time.Now()
+	output, err := c.client.DescribeKey(ctx, params, optFns...)
+	c.observe("DescribeKey", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) GetPublicKey(ctx context.Context, params *kms.GetPublicKeyInput, optFns ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	start := time.Now()
+	output, err := c.client.GetPublicKey(ctx, params, optFns...)
+	c.observe("GetPublicKey", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) Sign(ctx context.Context, params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error) {
+	start := time.Now()
+	output, err := c.client.Sign(ctx, params, optFns...)
+	c.observe("Sign", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) EnableKey(ctx context.Context, params *kms.EnableKeyInput, optFns ...func(*kms.Options)) (*kms.EnableKeyOutput, error) {
+	start := time.Now()
+	output, err := c.client.EnableKey(ctx, params, optFns...)
+	c.observe("EnableKey", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) DisableKey(ctx context.Context, params *kms.DisableKeyInput, optFns ...func(*kms.Options)) (*kms.DisableKeyOutput, error) {
+	start := time.Now()
+	output, err := c.client.DisableKey(ctx, params, optFns...)
+	c.observe("DisableKey", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) Verify(ctx context.Context, params *kms.VerifyInput, optFns ...func(*kms.Options)) (*kms.VerifyOutput, error) {
+	start := time.Now()
+	output, err := c.client.Verify(ctx, params, optFns...)
+	c.observe("Verify", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) ScheduleKeyDeletion(ctx context.Context, params *kms.ScheduleKeyDeletionInput, optFns ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error) {
+	start := time.Now()
+	output, err := c.client.ScheduleKeyDeletion(ctx, params, optFns...)
+	c.observe("ScheduleKeyDeletion", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) ListKeys(ctx context.Context, params *kms.ListKeysInput, optFns ...func(*kms.Options)) (*kms.ListKeysOutput, error) {
+	start := time.Now()
+	output, err := c.client.ListKeys(ctx, params, optFns...)
+	c.observe("ListKeys", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) ListAliases(ctx context.Context, params *kms.ListAliasesInput, optFns ...func(*kms.Options)) (*kms.ListAliasesOutput, error) {
+	start := time.Now()
+	output, err := c.client.ListAliases(ctx, params, optFns...)
+	c.observe("ListAliases", start, err)
+	return output, err
+}
+
+func (c *instrumentedClient) observe(operation string, start time.Time, err error) {
+	duration := time.Since(start)
+	if err == nil {
+		c.metrics.ObserveKMSCall(operation, KMSOutcomeSuccess, duration)
+		return
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && policyRejectionErrorCodes[apiErr.ErrorCode()] {
+		c.metrics.ObserveKMSCall(operation, KMSOutcomeRejected, duration)
+		c.metrics.IncPolicyRejection(operation, apiErr.ErrorCode())
+		return
+	}
+
+	c.metrics.ObserveKMSCall(operation, KMSOutcomeError, duration)
+}
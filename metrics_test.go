@@ -0,0 +1,140 @@
+package kmswallet_test
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingMetrics struct {
+	mu                sync.Mutex
+	kmsCalls          map[string]int
+	cacheHits         int
+	cacheMisses       int
+	recoveryIdRetries int
+	policyRejections  map[string]int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		kmsCalls:         map[string]int{},
+		policyRejections: map[string]int{},
+	}
+}
+
+func (m *recordingMetrics) ObserveKMSCall(operation string, outcome string, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kmsCalls[operation+":"+outcome]++
+}
+
+func (m *recordingMetrics) IncPublicKeyCacheHit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cacheHits++
+}
+
+func (m *recordingMetrics) IncPublicKeyCacheMiss() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cacheMisses++
+}
+
+func (m *recordingMetrics) IncRecoveryIdRetry() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recoveryIdRetries++
+}
+
+func (m *recordingMetrics) IncPolicyRejection(operation string, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policyRejections[operation+":"+reason]++
+}
+
+func TestMetrics_Should_Record_Cache_Hits_And_Misses(t *testing.T) {
+	// given
+	mockClient := &mockKMSClient{}
+	metrics := newRecordingMetrics()
+	provider := kmswallet.NewProvider(mockClient, nil, kmswallet.WithMetrics(metrics))
+
+	publicKey, _ := base64.StdEncoding.DecodeString("MFYwEAYHKoZIzj0CAQYFK4EEAAoDQgAERtrxsFyn7UzP2OgzzJA6Y89p/2175fOwXeP33ACZgmdD2jJlQdypNM9CCDm3J6uqTrvYrO0hwF8p/k/Tf94DjA==")
+	mockClient.On("GetPublicKey", mock.Anything, mock.Anything, mock.Anything).Return(&kms.GetPublicKeyOutput{
+		PublicKey: publicKey,
+	}, nil)
+
+	// when
+	_, err1 := provider.GetWallet(context.Background(), "keyId")
+	_, err2 := provider.GetWallet(context.Background(), "keyId")
+
+	// then
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, 1, metrics.cacheMisses)
+	assert.Equal(t, 1, metrics.cacheHits)
+	assert.Equal(t, 1, metrics.kmsCalls["GetPublicKey:success"])
+}
+
+func TestMetrics_Should_Record_Sign_Calls_And_Recovery_Id_Retries(t *testing.T) {
+	// given
+	mockClient := &mockKMSClient{}
+	metrics := newRecordingMetrics()
+	provider := kmswallet.NewProvider(mockClient, nil, kmswallet.WithMetrics(metrics))
+	publicKey, _ := base64.StdEncoding.DecodeString("MFYwEAYHKoZIzj0CAQYFK4EEAAoDQgAE1TWYYp+bySM6J3m99CxKhHZFgJqdwm5V6kGziuJ4kc7f0xORg5AqGRKbFNTSsmYTXNi2Z/cl298eyKTbmy+8aQ==")
+	signOutput, _ := base64.StdEncoding.DecodeString("MEUCIQD6wEcOzyjl8wr+OR8In54bVKgR5/ZogQQWiHPkqb70NQIgZZnGhIRlfGj9xexxACWQ4WZPB60swZP5DK6OjyEJIIc=")
+
+	mockClient.On("GetPublicKey", mock.Anything, mock.Anything, mock.Anything).Return(&kms.GetPublicKeyOutput{
+		PublicKey: publicKey,
+	}, nil)
+
+	mockClient.On("Sign", mock.Anything, mock.Anything, mock.Anything).Return(&kms.SignOutput{
+		Signature: signOutput,
+	}, nil)
+
+	// when
+	_, err := provider.SignMessage(context.Background(), "keyId", []byte("Hello World!"))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 1, metrics.kmsCalls["Sign:success"])
+	assert.Equal(t, 1, metrics.recoveryIdRetries)
+}
+
+func TestMetrics_Should_Record_Policy_Rejections(t *testing.T) {
+	// given
+	mockClient := &mockKMSClient{}
+	metrics := newRecordingMetrics()
+	provider := kmswallet.NewProvider(mockClient, nil, kmswallet.WithMetrics(metrics))
+
+	mockClient.On("DisableKey", mock.Anything, mock.Anything, mock.Anything).Return((*kms.DisableKeyOutput)(nil), &smithy.GenericAPIError{
+		Code:    "AccessDeniedException",
+		Message: "not authorized",
+	})
+
+	// when
+	_, err := provider.DisableWallet(context.Background(), "keyId")
+
+	// then
+	assert.Error(t, err)
+	assert.Equal(t, 1, metrics.kmsCalls["DisableKey:rejected"])
+	assert.Equal(t, 1, metrics.policyRejections["DisableKey:AccessDeniedException"])
+}
+
+func TestWithMetrics_Should_Ignore_Nil_Metrics(t *testing.T) {
+	// given
+	mockClient := newSignMessageMockClient("MFYwEAYHKoZIzj0CAQYFK4EEAAoDQgAE1TWYYp+bySM6J3m99CxKhHZFgJqdwm5V6kGziuJ4kc7f0xORg5AqGRKbFNTSsmYTXNi2Z/cl298eyKTbmy+8aQ==")
+	provider := kmswallet.NewProvider(mockClient, nil, kmswallet.WithMetrics(nil))
+
+	// when
+	_, err := provider.SignMessage(context.Background(), "keyId", []byte("Hello World!"))
+
+	// then
+	assert.NoError(t, err)
+}
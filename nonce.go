@@ -0,0 +1,183 @@
+package kmswallet
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type nonceState struct {
+	mu       sync.Mutex
+	synced   bool
+	resync   bool
+	next     uint64
+	floor    uint64
+	released []uint64
+	reserved map[uint64]bool
+}
+
+// NonceManager hands out nonces per address so that several goroutines can send transactions from the same wallet
+// without colliding. The first nonce is fetched from the backend's pending state; later ones are assigned locally.
+// The nonces are those of the backend's chain, so use one manager per chain.
+type NonceManager struct {
+	backend bind.ContractBackend
+	mu      sync.Mutex
+	states  map[common.Address]*nonceState
+}
+
+func NewNonceManager(backend bind.ContractBackend) *NonceManager {
+	return &NonceManager{
+		backend: backend,
+		states:  map[common.Address]*nonceState{},
+	}
+}
+
+// Next reserves the next nonce for the address. Released nonces are reused first, lowest first, so gaps are filled.
+// Report every reservation with HandleSendError, Release or MarkUsed.
+func (m *NonceManager) Next(ctx context.Context, address common.Address) (uint64, error) {
+	state := m.state(address)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if !state.synced {
+		pendingNonce, err := m.backend.PendingNonceAt(ctx, address)
+		if err != nil {
+			return 0, err
+		}
+
+		state.next = max(pendingNonce, state.floor)
+		state.floor = 0
+		state.released = nil
+		state.synced = true
+	}
+
+	nonce := state.next
+	if len(state.released) > 0 {
+		nonce = state.released[0]
+		state.released = state.released[1:]
+	} else {
+		state.next++
+	}
+
+	state.reserved[nonce] = true
+	return nonce, nil
+}
+
+// Release returns a reserved nonce whose transaction was never broadcast, so the next caller reuses it. Release every
+// reservation at most once: once the nonce is handed out again, a second release would hand it out twice.
+func (m *NonceManager) Release(address common.Address, nonce uint64) {
+	state := m.state(address)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if !state.reserved[nonce] {
+		return
+	}
+
+	state.unreserve(nonce)
+	if !state.synced || state.resync {
+		return
+	}
+
+	if nonce == state.next-1 {
+		state.next--
+		return
+	}
+
+	state.released = append(state.released, nonce)
+	sort.Slice(state.released, func(i, j int) bool { return state.released[i] < state.released[j] })
+}
+
+// MarkUsed tells the manager that a transaction with the nonce was sent, reserved or not, so the nonce is not handed
+// out again and later reservations continue after it.
+func (m *NonceManager) MarkUsed(address common.Address, nonce uint64) {
+	state := m.state(address)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.reserved[nonce] {
+		state.unreserve(nonce)
+	}
+
+	if !state.synced {
+		state.floor = max(state.floor, nonce+1)
+		return
+	}
+
+	state.next = max(state.next, nonce+1)
+	released := state.released[:0]
+	for _, r := range state.released {
+		if r != nonce {
+			released = append(released, r)
+		}
+	}
+
+	state.released = released
+}
+
+// Reset drops the local state of the address, so the next nonce is fetched from the backend again. While nonces are
+// reserved, the backend can not know about them yet, so the reset waits until every reservation is reported.
+func (m *NonceManager) Reset(address common.Address) {
+	state := m.state(address)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.resync = true
+	state.unreserve()
+}
+
+// HandleSendError updates the state of the address according to the result of sending a transaction with the nonce,
+// nil when it was sent. Errors telling that a transaction with the nonce is already pending ("already known",
+// "replacement transaction underpriced") mark the nonce used. Nonce errors ("nonce too low", "nonce too high")
+// resynchronize with the backend, other errors release the nonce.
+func (m *NonceManager) HandleSendError(address common.Address, nonce uint64, err error) {
+	if err == nil {
+		m.MarkUsed(address, nonce)
+		return
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "already known"), strings.Contains(message, "known transaction"),
+		strings.Contains(message, "replacement transaction underpriced"):
+		m.MarkUsed(address, nonce)
+	case strings.Contains(message, "nonce too low"):
+		m.Reset(address)
+		m.MarkUsed(address, nonce)
+	case strings.Contains(message, "nonce too high"):
+		m.Reset(address)
+		m.Release(address, nonce)
+	default:
+		m.Release(address, nonce)
+	}
+}
+
+func (m *NonceManager) state(address common.Address) *nonceState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.states[address]
+	if !ok {
+		state = &nonceState{reserved: map[uint64]bool{}}
+		m.states[address] = state
+	}
+
+	return state
+}
+
+// unreserve removes the nonces from the reservations, and drops the local state once a pending reset has no
+// reservations left to wait for.
+func (s *nonceState) unreserve(nonces ...uint64) {
+	for _, nonce := range nonces {
+		delete(s.reserved, nonce)
+	}
+
+	if s.resync && len(s.reserved) == 0 {
+		s.synced = false
+		s.resync = false
+		s.released = nil
+	}
+}
@@ -0,0 +1,255 @@
+package kmswallet_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+var nonceTestAddress = common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+
+func TestNonceManager_Should_Hand_Out_Unique_Nonces_Concurrently(t *testing.T) {
+	// given
+	backend := newFakeBackend()
+	backend.pendingNonce = 5
+	nonces := kmswallet.NewNonceManager(backend)
+
+	// when
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var handedOut []uint64
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := nonces.Next(context.Background(), nonceTestAddress)
+			assert.NoError(t, err)
+			mu.Lock()
+			handedOut = append(handedOut, nonce)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// then
+	sort.Slice(handedOut, func(i, j int) bool { return handedOut[i] < handedOut[j] })
+	for i, nonce := range handedOut {
+		assert.Equal(t, uint64(5+i), nonce)
+	}
+	assert.Equal(t, 1, backend.pendingNonceCalls)
+}
+
+func TestNonceManager_Should_Keep_Nonces_Per_Address(t *testing.T) {
+	// given
+	backend := newFakeBackend()
+	backend.pendingNonce = 3
+	nonces := kmswallet.NewNonceManager(backend)
+	otherAddress := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+
+	// when
+	first, _ := nonces.Next(context.Background(), nonceTestAddress)
+	second, _ := nonces.Next(context.Background(), otherAddress)
+
+	// then
+	assert.Equal(t, uint64(3), first)
+	assert.Equal(t, uint64(3), second)
+}
+
+func TestNonceManager_Should_Reuse_Released_Nonces_To_Fill_Gaps(t *testing.T) {
+	// given
+	backend := newFakeBackend()
+	backend.pendingNonce = 0
+	nonces := kmswallet.NewNonceManager(backend)
+	for i := 0; i < 3; i++ {
+		_, _ = nonces.Next(context.Background(), nonceTestAddress)
+	}
+
+	// when
+	nonces.HandleSendError(nonceTestAddress, 1, errors.New("insufficient funds for gas * price + value"))
+	reused, _ := nonces.Next(context.Background(), nonceTestAddress)
+	next, _ := nonces.Next(context.Background(), nonceTestAddress)
+
+	// then
+	assert.Equal(t, uint64(1), reused)
+	assert.Equal(t, uint64(3), next)
+}
+
+func TestNonceManager_Should_Resync_When_Nonce_Too_Low(t *testing.T) {
+	// given
+	backend := newFakeBackend()
+	backend.pendingNonce = 0
+	nonces := kmswallet.NewNonceManager(backend)
+	nonce, _ := nonces.Next(context.Background(), nonceTestAddress)
+	backend.setPendingNonce(10)
+
+	// when
+	nonces.HandleSendError(nonceTestAddress, nonce, errors.New("nonce too low: next nonce 10, tx nonce 0"))
+	next, err := nonces.Next(context.Background(), nonceTestAddress)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), next)
+	assert.Equal(t, 2, backend.pendingNonceCalls)
+}
+
+func TestNonceManager_MarkUsed(t *testing.T) {
+	// given
+	backend := newFakeBackend()
+	backend.pendingNonce = 3
+	nonces := kmswallet.NewNonceManager(backend)
+	otherAddress := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+
+	// when
+	nonces.MarkUsed(otherAddress, 3)
+	unsynced, _ := nonces.Next(context.Background(), otherAddress)
+
+	first, _ := nonces.Next(context.Background(), nonceTestAddress)
+	second, _ := nonces.Next(context.Background(), nonceTestAddress)
+	nonces.HandleSendError(nonceTestAddress, first, errors.New("insufficient funds for gas * price + value"))
+	nonces.MarkUsed(nonceTestAddress, 6)
+	reused, _ := nonces.Next(context.Background(), nonceTestAddress)
+	next, _ := nonces.Next(context.Background(), nonceTestAddress)
+
+	// then
+	assert.Equal(t, uint64(4), unsynced)
+	assert.Equal(t, uint64(4), second)
+	assert.Equal(t, uint64(3), reused)
+	assert.Equal(t, uint64(7), next)
+}
+
+func TestNonceManager_Should_Resync_Only_Once_Reservations_Are_Reported(t *testing.T) {
+	// given
+	backend := newFakeBackend()
+	backend.pendingNonce = 0
+	nonces := kmswallet.NewNonceManager(backend)
+	tooHigh, _ := nonces.Next(context.Background(), nonceTestAddress)
+	outstanding, _ := nonces.Next(context.Background(), nonceTestAddress)
+
+	// when
+	nonces.HandleSendError(nonceTestAddress, tooHigh, errors.New("nonce too high"))
+	beforeReport, _ := nonces.Next(context.Background(), nonceTestAddress)
+	nonces.HandleSendError(nonceTestAddress, outstanding, errors.New("insufficient funds for gas * price + value"))
+	nonces.HandleSendError(nonceTestAddress, beforeReport, errors.New("insufficient funds for gas * price + value"))
+	afterReport, err := nonces.Next(context.Background(), nonceTestAddress)
+
+	// then
+	assert.Equal(t, uint64(2), beforeReport)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), afterReport)
+	assert.Equal(t, 2, backend.pendingNonceCalls)
+}
+
+func TestNonceManager_Should_Mark_Nonce_Used_When_A_Transaction_Is_Pending(t *testing.T) {
+	// given
+	backend := newFakeBackend()
+	backend.pendingNonce = 0
+	nonces := kmswallet.NewNonceManager(backend)
+	known, _ := nonces.Next(context.Background(), nonceTestAddress)
+	underpriced, _ := nonces.Next(context.Background(), nonceTestAddress)
+
+	// when
+	nonces.HandleSendError(nonceTestAddress, known, errors.New("already known"))
+	nonces.HandleSendError(nonceTestAddress, underpriced, errors.New("replacement transaction underpriced"))
+	next, _ := nonces.Next(context.Background(), nonceTestAddress)
+
+	// then
+	assert.Equal(t, uint64(2), next)
+	assert.Equal(t, 1, backend.pendingNonceCalls)
+}
+
+func TestGetManagedTransactor(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	backend.pendingNonce = 7
+	nonces := kmswallet.NewNonceManager(backend)
+
+	// when
+	first, err1 := provider.GetManagedTransactor(context.Background(), keyId, big.NewInt(1), nonces)
+	second, err2 := provider.GetManagedTransactor(context.Background(), keyId, big.NewInt(1), nonces)
+
+	// then
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, big.NewInt(7), first.Nonce)
+	assert.Equal(t, big.NewInt(8), second.Nonce)
+}
+
+func TestGetManagedTransactor_Should_Release_Nonce_Once_When_Signing_Failed(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	backend.pendingNonce = 7
+	nonces := kmswallet.NewNonceManager(backend)
+	chainId := big.NewInt(1)
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		return errors.New("throttled")
+	})
+
+	failed, err := provider.GetManagedTransactor(context.Background(), keyId, chainId, nonces)
+	assert.NoError(t, err)
+
+	// when
+	_, signErr := failed.Signer(failed.From, newTestTransaction())
+	other, _ := provider.GetManagedTransactor(context.Background(), keyId, chainId, nonces)
+	nonces.HandleSendError(failed.From, failed.Nonce.Uint64(), signErr)
+	reused, _ := provider.GetManagedTransactor(context.Background(), keyId, chainId, nonces)
+
+	// then
+	assert.Error(t, signErr)
+	assert.Equal(t, big.NewInt(8), other.Nonce)
+	assert.Equal(t, big.NewInt(7), reused.Nonce)
+}
+
+func TestGetManagedTransactor_Should_Not_Hand_Out_A_Nonce_Twice_When_Signing_Fails_Concurrently(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	nonces := kmswallet.NewNonceManager(backend)
+	chainId := big.NewInt(1)
+	var calls atomic.Int32
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		if calls.Add(1)%3 == 0 {
+			return errors.New("throttled")
+		}
+
+		return nil
+	})
+
+	// when
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sent := map[uint64]int{}
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts, err := provider.GetManagedTransactor(context.Background(), keyId, chainId, nonces)
+			assert.NoError(t, err)
+
+			_, err = opts.Signer(opts.From, newTestTransaction())
+			nonces.HandleSendError(opts.From, opts.Nonce.Uint64(), err)
+			if err == nil {
+				mu.Lock()
+				sent[opts.Nonce.Uint64()]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// then
+	for nonce, count := range sent {
+		assert.Equal(t, 1, count, "nonce %d", nonce)
+	}
+}
@@ -0,0 +1,200 @@
+package kmswallet
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"math/big"
+)
+
+// Permit2Address is the address of the Uniswap Permit2 contract, which is the same on every chain.
+var Permit2Address = common.HexToAddress("0x000000000022D473030F116dDEE9F6B43aC78BA3")
+
+var permit2DetailsType = []apitypes.Type{
+	{Name: "token", Type: "address"},
+	{Name: "amount", Type: "uint160"},
+	{Name: "expiration", Type: "uint48"},
+	{Name: "nonce", Type: "uint48"},
+}
+
+// PermitToken is the EIP-712 domain of an EIP-2612 token: its address and the name and version of its domain.
+type PermitToken struct {
+	Address common.Address
+	Name    string
+	Version string
+}
+
+// PermitSignature is a signature split into the v, r and s arguments of permit functions.
+type PermitSignature struct {
+	V uint8
+	R [32]byte
+	S [32]byte
+}
+
+type Permit2Details struct {
+	Token      common.Address
+	Amount     *big.Int
+	Expiration uint64
+	Nonce      uint64
+}
+
+type PermitSingle struct {
+	Details     Permit2Details
+	Spender     common.Address
+	SigDeadline *big.Int
+}
+
+type PermitBatch struct {
+	Details     []Permit2Details
+	Spender     common.Address
+	SigDeadline *big.Int
+}
+
+// Bytes returns the 65 bytes [R || S || V] form of the signature, as expected by Permit2's permit functions.
+func (s PermitSignature) Bytes() []byte {
+	return append(append(append([]byte{}, s.R[:]...), s.S[:]...), s.V)
+}
+
+// PermitTypedData returns the EIP-2612 Permit typed data of owner allowing spender to spend value of token.
+func PermitTypedData(owner common.Address, token PermitToken, spender common.Address, value *big.Int, nonce *big.Int, deadline *big.Int, chainId *big.Int) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Permit": {
+				{Name: "owner", Type: "address"},
+				{Name: "spender", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "deadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Permit",
+		Domain: apitypes.TypedDataDomain{
+			Name:              token.Name,
+			Version:           token.Version,
+			ChainId:           (*math.HexOrDecimal256)(chainId),
+			VerifyingContract: token.Address.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"owner":    owner.Hex(),
+			"spender":  spender.Hex(),
+			"value":    value.String(),
+			"nonce":    nonce.String(),
+			"deadline": deadline.String(),
+		},
+	}
+}
+
+// PermitSingleTypedData returns the Permit2 AllowanceTransfer PermitSingle typed data of permit.
+func PermitSingleTypedData(permit PermitSingle, chainId *big.Int) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain":  permit2DomainType(),
+			"PermitDetails": permit2DetailsType,
+			"PermitSingle": {
+				{Name: "details", Type: "PermitDetails"},
+				{Name: "spender", Type: "address"},
+				{Name: "sigDeadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "PermitSingle",
+		Domain:      permit2Domain(chainId),
+		Message: apitypes.TypedDataMessage{
+			"details":     permit.Details.message(),
+			"spender":     permit.Spender.Hex(),
+			"sigDeadline": permit.SigDeadline.String(),
+		},
+	}
+}
+
+// PermitBatchTypedData returns the Permit2 AllowanceTransfer PermitBatch typed data of permit.
+func PermitBatchTypedData(permit PermitBatch, chainId *big.Int) apitypes.TypedData {
+	details := make([]interface{}, len(permit.Details))
+	for i, detail := range permit.Details {
+		details[i] = detail.message()
+	}
+
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain":  permit2DomainType(),
+			"PermitDetails": permit2DetailsType,
+			"PermitBatch": {
+				{Name: "details", Type: "PermitDetails[]"},
+				{Name: "spender", Type: "address"},
+				{Name: "sigDeadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "PermitBatch",
+		Domain:      permit2Domain(chainId),
+		Message: apitypes.TypedDataMessage{
+			"details":     details,
+			"spender":     permit.Spender.Hex(),
+			"sigDeadline": permit.SigDeadline.String(),
+		},
+	}
+}
+
+// SignPermit signs an EIP-2612 permit of the wallet allowing spender to spend value of token until deadline.
+func (c *provider) SignPermit(
+	ctx context.Context, keyId string, token PermitToken, spender common.Address, value *big.Int, nonce *big.Int, deadline *big.Int, chainId *big.Int,
+) (*PermitSignature, error) {
+	wallet, err := c.GetWallet(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.signPermitTypedData(ctx, keyId, PermitTypedData(common.HexToAddress(wallet.Address), token, spender, value, nonce, deadline, chainId))
+}
+
+// SignPermitSingle signs a Uniswap Permit2 PermitSingle allowance.
+func (c *provider) SignPermitSingle(ctx context.Context, keyId string, permit PermitSingle, chainId *big.Int) (*PermitSignature, error) {
+	return c.signPermitTypedData(ctx, keyId, PermitSingleTypedData(permit, chainId))
+}
+
+// SignPermitBatch signs a Uniswap Permit2 PermitBatch allowance.
+func (c *provider) SignPermitBatch(ctx context.Context, keyId string, permit PermitBatch, chainId *big.Int) (*PermitSignature, error) {
+	return c.signPermitTypedData(ctx, keyId, PermitBatchTypedData(permit, chainId))
+}
+
+func (c *provider) signPermitTypedData(ctx context.Context, keyId string, typedData apitypes.TypedData) (*PermitSignature, error) {
+	signature, err := c.SignTypedData(ctx, keyId, typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	permitSignature := &PermitSignature{V: signature[64]}
+	copy(permitSignature.R[:], signature[:32])
+	copy(permitSignature.S[:], signature[32:64])
+	return permitSignature, nil
+}
+
+func (d Permit2Details) message() map[string]interface{} {
+	return map[string]interface{}{
+		"token":      d.Token.Hex(),
+		"amount":     d.Amount.String(),
+		"expiration": new(big.Int).SetUint64(d.Expiration).String(),
+		"nonce":      new(big.Int).SetUint64(d.Nonce).String(),
+	}
+}
+
+func permit2DomainType() []apitypes.Type {
+	return []apitypes.Type{
+		{Name: "name", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	}
+}
+
+func permit2Domain(chainId *big.Int) apitypes.TypedDataDomain {
+	return apitypes.TypedDataDomain{
+		Name:              "Permit2",
+		ChainId:           (*math.HexOrDecimal256)(chainId),
+		VerifyingContract: Permit2Address.Hex(),
+	}
+}
@@ -0,0 +1,139 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func word(value *big.Int) []byte {
+	return common.LeftPadBytes(value.Bytes(), 32)
+}
+
+func eip712Digest(domainSeparator []byte, structHash []byte) []byte {
+	return crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, structHash)
+}
+
+func permitSigner(t *testing.T, digest []byte, signature *kmswallet.PermitSignature) common.Address {
+	assert.Contains(t, []uint8{27, 28}, signature.V)
+	publicKey, err := crypto.SigToPub(digest, append(append(signature.R[:], signature.S[:]...), signature.V-27))
+	assert.NoError(t, err)
+	return crypto.PubkeyToAddress(*publicKey)
+}
+
+func permit2DetailsHash(details kmswallet.Permit2Details) []byte {
+	return crypto.Keccak256(
+		crypto.Keccak256([]byte("PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)")),
+		common.LeftPadBytes(details.Token.Bytes(), 32),
+		word(details.Amount),
+		word(new(big.Int).SetUint64(details.Expiration)),
+		word(new(big.Int).SetUint64(details.Nonce)),
+	)
+}
+
+func permit2DomainSeparator(chainId *big.Int) []byte {
+	return crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(string name,uint256 chainId,address verifyingContract)")),
+		crypto.Keccak256([]byte("Permit2")),
+		word(chainId),
+		common.LeftPadBytes(kmswallet.Permit2Address.Bytes(), 32),
+	)
+}
+
+func TestSignPermit(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	owner := client.Address(keyId)
+	token := kmswallet.PermitToken{Address: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), Name: "USD Coin", Version: "2"}
+	spender := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+	value, nonce, deadline, chainId := big.NewInt(1_000_000), big.NewInt(3), big.NewInt(1_700_000_000), big.NewInt(1)
+
+	domainSeparator := crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)")),
+		crypto.Keccak256([]byte(token.Name)),
+		crypto.Keccak256([]byte(token.Version)),
+		word(chainId),
+		common.LeftPadBytes(token.Address.Bytes(), 32),
+	)
+	structHash := crypto.Keccak256(
+		crypto.Keccak256([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)")),
+		common.LeftPadBytes(owner.Bytes(), 32),
+		common.LeftPadBytes(spender.Bytes(), 32),
+		word(value),
+		word(nonce),
+		word(deadline),
+	)
+
+	// when
+	signature, err := provider.SignPermit(context.Background(), keyId, token, spender, value, nonce, deadline, chainId)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, owner, permitSigner(t, eip712Digest(domainSeparator, structHash), signature))
+	assert.Equal(t, append(append(signature.R[:], signature.S[:]...), signature.V), signature.Bytes())
+}
+
+func TestSignPermitSingle(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	chainId := big.NewInt(10)
+	permit := kmswallet.PermitSingle{
+		Details: kmswallet.Permit2Details{
+			Token:      common.HexToAddress("0x0b2C639c533813f4Aa9D7837CAf62653d097Ff85"),
+			Amount:     big.NewInt(5_000_000),
+			Expiration: 1_700_086_400,
+			Nonce:      7,
+		},
+		Spender:     common.HexToAddress("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
+		SigDeadline: big.NewInt(1_700_001_800),
+	}
+
+	structHash := crypto.Keccak256(
+		crypto.Keccak256([]byte("PermitSingle(PermitDetails details,address spender,uint256 sigDeadline)PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)")),
+		permit2DetailsHash(permit.Details),
+		common.LeftPadBytes(permit.Spender.Bytes(), 32),
+		word(permit.SigDeadline),
+	)
+
+	// when
+	signature, err := provider.SignPermitSingle(context.Background(), keyId, permit, chainId)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), permitSigner(t, eip712Digest(permit2DomainSeparator(chainId), structHash), signature))
+}
+
+func TestSignPermitBatch(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	chainId := big.NewInt(1)
+	permit := kmswallet.PermitBatch{
+		Details: []kmswallet.Permit2Details{
+			{Token: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), Amount: big.NewInt(1), Expiration: 1_700_086_400, Nonce: 0},
+			{Token: common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7"), Amount: big.NewInt(2), Expiration: 1_700_086_400, Nonce: 4},
+		},
+		Spender:     common.HexToAddress("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
+		SigDeadline: big.NewInt(1_700_001_800),
+	}
+
+	structHash := crypto.Keccak256(
+		crypto.Keccak256([]byte("PermitBatch(PermitDetails[] details,address spender,uint256 sigDeadline)PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)")),
+		crypto.Keccak256(permit2DetailsHash(permit.Details[0]), permit2DetailsHash(permit.Details[1])),
+		common.LeftPadBytes(permit.Spender.Bytes(), 32),
+		word(permit.SigDeadline),
+	)
+
+	// when
+	signature, err := provider.SignPermitBatch(context.Background(), keyId, permit, chainId)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), permitSigner(t, eip712Digest(permit2DomainSeparator(chainId), structHash), signature))
+}
@@ -0,0 +1,94 @@
+package prommetrics
+
+import (
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+type Metrics struct {
+	kmsCalls          *prometheus.CounterVec
+	kmsCallDuration   *prometheus.HistogramVec
+	cacheHits         prometheus.Counter
+	cacheMisses       prometheus.Counter
+	recoveryIdRetries prometheus.Counter
+	policyRejections  *prometheus.CounterVec
+}
+
+var _ kmswallet.Metrics = (*Metrics)(nil)
+
+// New creates the provider collectors under the given namespace and registers them with registerer.
+func New(registerer prometheus.Registerer, namespace string) (*Metrics, error) {
+	m := &Metrics{
+		kmsCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kms_wallet",
+			Name:      "kms_calls_total",
+			Help:      "Number of AWS KMS API calls by operation and outcome.",
+		}, []string{"operation", "outcome"}),
+		kmsCallDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "kms_wallet",
+			Name:      "kms_call_duration_seconds",
+			Help:      "Latency of AWS KMS API calls by operation and outcome.",
+			Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		}, []string{"operation", "outcome"}),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kms_wallet",
+			Name:      "public_key_cache_hits_total",
+			Help:      "Number of public key lookups served from the cache.",
+		}),
+		cacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kms_wallet",
+			Name:      "public_key_cache_misses_total",
+			Help:      "Number of public key lookups that had to call KMS.",
+		}),
+		recoveryIdRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kms_wallet",
+			Name:      "recovery_id_retries_total",
+			Help:      "Number of signatures that needed the second recovery id candidate.",
+		}),
+		policyRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "kms_wallet",
+			Name:      "policy_rejections_total",
+			Help:      "Number of requests refused by a key policy or key state.",
+		}, []string{"operation", "reason"}),
+	}
+
+	collectors := []prometheus.Collector{
+		m.kmsCalls, m.kmsCallDuration, m.cacheHits, m.cacheMisses, m.recoveryIdRetries, m.policyRejections,
+	}
+
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) ObserveKMSCall(operation string, outcome string, duration time.Duration) {
+	m.kmsCalls.WithLabelValues(operation, outcome).Inc()
+	m.kmsCallDuration.WithLabelValues(operation, outcome).Observe(duration.Seconds())
+}
+
+func (m *Metrics) IncPublicKeyCacheHit() {
+	m.cacheHits.Inc()
+}
+
+func (m *Metrics) IncPublicKeyCacheMiss() {
+	m.cacheMisses.Inc()
+}
+
+func (m *Metrics) IncRecoveryIdRetry() {
+	m.recoveryIdRetries.Inc()
+}
+
+func (m *Metrics) IncPolicyRejection(operation string, reason string) {
+	m.policyRejections.WithLabelValues(operation, reason).Inc()
+}
@@ -0,0 +1,37 @@
+package prommetrics_test
+
+import (
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/prommetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	// given
+	registry := prometheus.NewRegistry()
+	metrics, err := prommetrics.New(registry, "test")
+	assert.NoError(t, err)
+
+	// when
+	metrics.ObserveKMSCall("Sign", "success", 50*time.Millisecond)
+	metrics.ObserveKMSCall("Sign", "success", 70*time.Millisecond)
+	metrics.IncPublicKeyCacheHit()
+	metrics.IncPublicKeyCacheMiss()
+	metrics.IncRecoveryIdRetry()
+	metrics.IncPolicyRejection("Sign", "DisabledException")
+
+	// then
+	count, err := testutil.GatherAndCount(registry,
+		"test_kms_wallet_kms_calls_total",
+		"test_kms_wallet_public_key_cache_hits_total",
+		"test_kms_wallet_policy_rejections_total",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	_, err = prommetrics.New(registry, "test")
+	assert.Error(t, err)
+}
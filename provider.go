@@ -16,8 +16,11 @@ import (
 	ether_types "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/secp256k1"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/patrickmn/go-cache"
+	"log/slog"
 	"math/big"
+	"sync"
 	"time"
 )
 
@@ -56,6 +59,10 @@ type KMSClient interface {
 	Sign(ctx context.Context, params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error)
 	EnableKey(ctx context.Context, params *kms.EnableKeyInput, optFns ...func(*kms.Options)) (*kms.EnableKeyOutput, error)
 	DisableKey(ctx context.Context, params *kms.DisableKeyInput, optFns ...func(*kms.Options)) (*kms.DisableKeyOutput, error)
+	Verify(ctx context.Context, params *kms.VerifyInput, optFns ...func(*kms.Options)) (*kms.VerifyOutput, error)
+	ScheduleKeyDeletion(ctx context.Context, params *kms.ScheduleKeyDeletionInput, optFns ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error)
+	ListKeys(ctx context.Context, params *kms.ListKeysInput, optFns ...func(*kms.Options)) (*kms.ListKeysOutput, error)
+	ListAliases(ctx context.Context, params *kms.ListAliasesInput, optFns ...func(*kms.Options)) (*kms.ListAliasesOutput, error)
 }
 
 type KMSWallet struct {
@@ -80,34 +87,119 @@ type CreateWalletInput struct {
 type Provider interface {
 	CreateWallet(ctx context.Context, input CreateWalletInput) (wallet KMSWallet, err error)
 	GetWallet(ctx context.Context, keyId string) (wallet KMSWallet, err error)
+	GetPublicKey(ctx context.Context, keyId string) (*ecdsa.PublicKey, error)
+	GetWalletDetails(ctx context.Context, keyId string) (WalletDetails, error)
+	ListWallets(ctx context.Context) ([]WalletDetails, error)
 	GetWalletTransactor(ctx context.Context, keyId string, chainId *big.Int) (*bind.TransactOpts, error)
 	GetWalletCaller(ctx context.Context, keyId string, chainId *big.Int) (*bind.CallOpts, error)
-	SignMessage(ctx context.Context, keyId string, message []byte) ([]byte, error)
+	GetManagedTransactor(ctx context.Context, keyId string, chainId *big.Int, nonces *NonceManager) (*bind.TransactOpts, error)
+	SignMessage(ctx context.Context, keyId string, message []byte, opts ...SignOption) ([]byte, error)
+	SignHash(ctx context.Context, keyId string, hash []byte, opts ...SignOption) ([]byte, error)
+	SignTypedData(ctx context.Context, keyId string, typedData apitypes.TypedData, opts ...SignOption) ([]byte, error)
+	SignSiweMessage(ctx context.Context, keyId string, message SiweMessage) (text string, signature []byte, err error)
+	SignPermit(ctx context.Context, keyId string, token PermitToken, spender common.Address, value *big.Int, nonce *big.Int, deadline *big.Int, chainId *big.Int) (*PermitSignature, error)
+	SignPermitSingle(ctx context.Context, keyId string, permit PermitSingle, chainId *big.Int) (*PermitSignature, error)
+	SignPermitBatch(ctx context.Context, keyId string, permit PermitBatch, chainId *big.Int) (*PermitSignature, error)
+	SignUserOperation(ctx context.Context, keyId string, op *UserOperation, entryPoint common.Address, chainId *big.Int) ([]byte, error)
+	SignPackedUserOperation(ctx context.Context, keyId string, op *PackedUserOperation, entryPoint common.Address, chainId *big.Int) ([]byte, error)
+	SignFlashbotsPayload(ctx context.Context, keyId string, body []byte) (string, error)
+	VerifyWithKMS(ctx context.Context, keyId string, hash []byte, signature []byte) (bool, error)
+	SignHashes(ctx context.Context, keyId string, hashes [][32]byte, concurrency int) []SignatureResult
+	SignTransactions(ctx context.Context, keyId string, signer ether_types.Signer, txs []*ether_types.Transaction, concurrency int) []TransactionResult
+	SignTransaction(ctx context.Context, keyId string, signer ether_types.Signer, tx *ether_types.Transaction) (*ether_types.Transaction, error)
+	SignTransactionForChain(ctx context.Context, keyId string, chainId *big.Int, tx *ether_types.Transaction) (*ether_types.Transaction, error)
+	SignSetCodeAuthorization(ctx context.Context, keyId string, chainId *big.Int, delegateAddress common.Address, nonce uint64) (*SetCodeAuthorization, error)
 	EnableWallet(ctx context.Context, keyId string) (*kms.EnableKeyOutput, error)
 	DisableWallet(ctx context.Context, keyId string) (*kms.DisableKeyOutput, error)
+	ScheduleWalletDeletion(ctx context.Context, keyId string, pendingWindowInDays int32) (*kms.ScheduleKeyDeletionOutput, error)
 
 	GetWalletByAlias(ctx context.Context, alias string) (wallet KMSWallet, err error)
 	GetWalletTransactorByAlias(ctx context.Context, alias string, chainId *big.Int) (*bind.TransactOpts, error)
 	GetWalletCallerByAlias(ctx context.Context, alias string, chainId *big.Int) (*bind.CallOpts, error)
-	SignMessageByAlias(ctx context.Context, alias string, message []byte) ([]byte, error)
+	GetManagedTransactorByAlias(ctx context.Context, alias string, chainId *big.Int, nonces *NonceManager) (*bind.TransactOpts, error)
+	SignMessageByAlias(ctx context.Context, alias string, message []byte, opts ...SignOption) ([]byte, error)
+	SignHashByAlias(ctx context.Context, alias string, hash []byte, opts ...SignOption) ([]byte, error)
+	SignTypedDataByAlias(ctx context.Context, alias string, typedData apitypes.TypedData, opts ...SignOption) ([]byte, error)
+	SignTransactionByAlias(ctx context.Context, alias string, signer ether_types.Signer, tx *ether_types.Transaction) (*ether_types.Transaction, error)
 	EnableWalletByAlias(ctx context.Context, alias string) (*kms.EnableKeyOutput, error)
 	DisableWalletByAlias(ctx context.Context, alias string) (*kms.DisableKeyOutput, error)
+	ScheduleWalletDeletionByAlias(ctx context.Context, alias string, pendingWindowInDays int32) (*kms.ScheduleKeyDeletionOutput, error)
 	GetKeyIdByAlias(ctx context.Context, alias string) (keyId string, err error)
+	GetKeyIdByAliasWithAddress(ctx context.Context, alias string, address common.Address) (keyId string, err error)
+
+	ValidateRegistry(ctx context.Context) error
+	GetKeyIdByName(ctx context.Context, name string) (keyId string, err error)
+	GetTransactorByName(ctx context.Context, name string, chainId *big.Int) (*bind.TransactOpts, error)
 }
 type provider struct {
-	client KMSClient
-	cache  *cache.Cache
+	client       KMSClient
+	cache        *cache.Cache
+	metrics      Metrics
+	logger       *slog.Logger
+	logPlaintext bool
+	signTimeout  time.Duration
+	pins         AddressPinStore
+	autoPin      bool
+
+	registry       Registry
+	registryMu     sync.Mutex
+	registryKeyIds map[string]string
+}
+
+type ProviderOption func(p *provider)
+
+// WithMetrics reports KMS calls, cache events, recovery id retries and policy rejections to metrics. A nil metrics
+// keeps the no-op default.
+func WithMetrics(metrics Metrics) ProviderOption {
+	return func(p *provider) {
+		if metrics != nil {
+			p.metrics = metrics
+		}
+	}
+}
+
+// WithLogger sets the logger used for wallet creation, alias resolution, cache and signing events.
+// Message plaintext is never logged unless WithPlaintextLogging is also set. A nil logger keeps the library silent.
+func WithLogger(logger *slog.Logger) ProviderOption {
+	return func(p *provider) {
+		if logger != nil {
+			p.logger = logger
+		}
+	}
+}
+
+// WithPlaintextLogging allows the messages passed to SignMessage to be logged at debug level.
+func WithPlaintextLogging() ProviderOption {
+	return func(p *provider) {
+		p.logPlaintext = true
+	}
+}
+
+// WithSignTimeout bounds every KMS Sign call with the given timeout, on top of the caller's context.
+func WithSignTimeout(timeout time.Duration) ProviderOption {
+	return func(p *provider) {
+		p.signTimeout = timeout
+	}
 }
 
-func NewProvider(client KMSClient, cacheExpiration *time.Duration) Provider {
+func NewProvider(client KMSClient, cacheExpiration *time.Duration, opts ...ProviderOption) Provider {
 	if cacheExpiration == nil {
 		cacheExpiration = &defaultCacheDuration
 	}
 
-	return &provider{
-		client: client,
-		cache:  cache.New(*cacheExpiration, time.Hour),
+	p := &provider{
+		cache:          cache.New(*cacheExpiration, time.Hour),
+		metrics:        noopMetrics{},
+		logger:         slog.New(discardHandler{}),
+		registryKeyIds: map[string]string{},
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.client = &instrumentedClient{client: client, metrics: p.metrics}
+	return p
 }
 
 func (c *provider) CreateWallet(ctx context.Context, input CreateWalletInput) (wallet KMSWallet, err error) {
@@ -121,6 +213,7 @@ func (c *provider) CreateWallet(ctx context.Context, input CreateWalletInput) (w
 		})
 	}
 
+	c.logger.DebugContext(ctx, "creating KMS key", slog.Int("tagCount", len(tags)))
 	output, err := c.client.CreateKey(ctx, &kms.CreateKeyInput{
 		BypassPolicyLockoutSafetyCheck: input.BypassPolicyLockoutSafetyCheck,
 		CustomKeyStoreId:               input.CustomKeyStoreId,
@@ -135,11 +228,14 @@ func (c *provider) CreateWallet(ctx context.Context, input CreateWalletInput) (w
 	})
 
 	if err != nil {
+		c.logger.ErrorContext(ctx, "can not create KMS key", slog.Any("err", err))
 		return wallet, err
 	}
 
+	c.logger.InfoContext(ctx, "created KMS key", slog.String("keyId", *output.KeyMetadata.KeyId))
 	wallet, err = c.GetWallet(ctx, *output.KeyMetadata.KeyId)
 	if err != nil {
+		c.logger.ErrorContext(ctx, "can not get wallet of created KMS key", slog.String("keyId", *output.KeyMetadata.KeyId), slog.Any("err", err))
 		return wallet, err
 	}
 
@@ -156,8 +252,11 @@ func (c *provider) CreateWallet(ctx context.Context, input CreateWalletInput) (w
 		})
 
 		if err != nil {
+			c.logger.ErrorContext(ctx, "can not create alias for KMS key", slog.String("keyId", wallet.KeyId), slog.String("alias", *alias), slog.Any("err", err))
 			return wallet, err
 		}
+
+		c.logger.DebugContext(ctx, "created alias for KMS key", slog.String("keyId", wallet.KeyId), slog.String("alias", *alias))
 	}
 
 	if input.AddWalletAddressTag {
@@ -172,10 +271,14 @@ func (c *provider) CreateWallet(ctx context.Context, input CreateWalletInput) (w
 		})
 
 		if err != nil {
+			c.logger.ErrorContext(ctx, "can not add wallet address tag to KMS key", slog.String("keyId", wallet.KeyId), slog.Any("err", err))
 			return wallet, err
 		}
+
+		c.logger.DebugContext(ctx, "added wallet address tag to KMS key", slog.String("keyId", wallet.KeyId))
 	}
 
+	c.logger.InfoContext(ctx, "created wallet", slog.String("keyId", wallet.KeyId), slog.String("address", wallet.Address))
 	return wallet, err
 }
 
@@ -192,6 +295,11 @@ func (c *provider) GetWallet(ctx context.Context, keyId string) (wallet KMSWalle
 	}, err
 }
 
+// GetPublicKey returns the secp256k1 public key of the wallet.
+func (c *provider) GetPublicKey(ctx context.Context, keyId string) (*ecdsa.PublicKey, error) {
+	return c.getPublicKey(ctx, keyId)
+}
+
 func (c *provider) GetWalletByAlias(ctx context.Context, alias string) (wallet KMSWallet, err error) {
 	keyId, err := c.GetKeyIdByAlias(ctx, alias)
 	if err != nil {
@@ -205,12 +313,18 @@ func (c *provider) DisableWallet(ctx context.Context, keyId string) (*kms.Disabl
 	return c.client.DisableKey(ctx, &kms.DisableKeyInput{KeyId: &keyId})
 }
 
+// DisableWalletByAlias disables the key alias points to. With WithAddressPinStore, the key has to derive the pinned
+// address of alias.
 func (c *provider) DisableWalletByAlias(ctx context.Context, alias string) (*kms.DisableKeyOutput, error) {
-	keyId, err := c.GetKeyIdByAlias(ctx, alias)
+	keyId, err := c.resolveAlias(ctx, alias)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := c.checkAliasPin(ctx, alias, keyId); err != nil {
+		return nil, err
+	}
+
 	return c.client.DisableKey(ctx, &kms.DisableKeyInput{KeyId: &keyId})
 }
 
@@ -219,7 +333,7 @@ func (c *provider) EnableWallet(ctx context.Context, keyId string) (*kms.EnableK
 }
 
 func (c *provider) EnableWalletByAlias(ctx context.Context, alias string) (*kms.EnableKeyOutput, error) {
-	keyId, err := c.GetKeyIdByAlias(ctx, alias)
+	keyId, err := c.resolveAlias(ctx, alias)
 	if err != nil {
 		return nil, err
 	}
@@ -227,58 +341,83 @@ func (c *provider) EnableWalletByAlias(ctx context.Context, alias string) (*kms.
 	return c.client.EnableKey(ctx, &kms.EnableKeyInput{KeyId: &keyId})
 }
 
-func (c *provider) GetWalletTransactor(ctx context.Context, keyId string, chainId *big.Int) (*bind.TransactOpts, error) {
-	publicKey, err := c.getPublicKey(ctx, keyId)
+// ScheduleWalletDeletion schedules the deletion of the KMS key after pendingWindowInDays, between 7 and 30 days.
+// Zero uses the KMS default of 30 days. The wallet can not sign while the deletion is pending.
+func (c *provider) ScheduleWalletDeletion(ctx context.Context, keyId string, pendingWindowInDays int32) (*kms.ScheduleKeyDeletionOutput, error) {
+	input := &kms.ScheduleKeyDeletionInput{KeyId: &keyId}
+	if pendingWindowInDays > 0 {
+		input.PendingWindowInDays = &pendingWindowInDays
+	}
+
+	output, err := c.client.ScheduleKeyDeletion(ctx, input)
 	if err != nil {
+		c.logger.ErrorContext(ctx, "can not schedule KMS key deletion", slog.String("keyId", keyId), slog.Any("err", err))
 		return nil, err
 	}
 
-	publicKeyBytes := secp256k1.S256().Marshal(publicKey.X, publicKey.Y)
-	publicKeyAddress := crypto.PubkeyToAddress(*publicKey)
-	if chainId == nil {
-		return nil, bind.ErrNoChainID
+	c.logger.InfoContext(ctx, "scheduled KMS key deletion", slog.String("keyId", keyId))
+	return output, nil
+}
+
+// ScheduleWalletDeletionByAlias schedules the deletion of the key alias points to, like ScheduleWalletDeletion. With
+// WithAddressPinStore, the key has to derive the pinned address of alias, so a disabled key whose public key is not
+// cached can only be scheduled for deletion by keyId.
+func (c *provider) ScheduleWalletDeletionByAlias(ctx context.Context, alias string, pendingWindowInDays int32) (*kms.ScheduleKeyDeletionOutput, error) {
+	keyId, err := c.resolveAlias(ctx, alias)
+	if err != nil {
+		return nil, err
 	}
 
-	signer := ether_types.LatestSignerForChainID(chainId)
-	signerFn := func(address common.Address, tx *ether_types.Transaction) (*ether_types.Transaction, error) {
-		if address != publicKeyAddress {
-			return nil, bind.ErrNotAuthorized
-		}
+	if err := c.checkAliasPin(ctx, alias, keyId); err != nil {
+		return nil, err
+	}
 
-		txHashBytes := signer.Hash(tx).Bytes()
+	return c.ScheduleWalletDeletion(ctx, keyId, pendingWindowInDays)
+}
 
-		rBytes, sBytes, err := c.getSignatureFromKms(ctx, keyId, txHashBytes)
-		if err != nil {
-			return nil, err
-		}
+// GetWalletTransactor returns TransactOpts that sign with the KMS key. ctx is only used to fetch the public key and
+// to carry values into later signing calls; its cancellation does not affect the returned signer. Each signature
+// uses the Context of the returned opts when it is set. bind passes only the address and the transaction to the
+// Signer, so setting Context on a copy of the opts has no effect; use WithContext to get a copy that signs with a
+// per-call context.
+func (c *provider) GetWalletTransactor(ctx context.Context, keyId string, chainId *big.Int) (*bind.TransactOpts, error) {
+	return c.newTransactor(ctx, keyId, chainId, nil)
+}
 
-		// Adjust S value from signature according to Ethereum standard
-		sBigInt := new(big.Int).SetBytes(sBytes)
-		if sBigInt.Cmp(secp256k1HalfN) > 0 {
-			sBytes = new(big.Int).Sub(secp256k1N, sBigInt).Bytes()
-		}
+func (c *provider) GetWalletTransactorByAlias(ctx context.Context, alias string, chainId *big.Int) (*bind.TransactOpts, error) {
+	keyId, err := c.GetKeyIdByAlias(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
 
-		signature, err := c.getEthereumSignature(publicKeyBytes, txHashBytes, rBytes, sBytes)
-		if err != nil {
-			return nil, err
-		}
+	return c.GetWalletTransactor(ctx, keyId, chainId)
+}
 
-		return tx.WithSignature(signer, signature)
+// GetManagedTransactor returns a transactor whose Nonce is reserved from nonces. It is meant for a single transaction:
+// get a new one per transaction and report the send result, including signing errors, with nonces.HandleSendError.
+// The transactor itself never releases the nonce, so that it is released exactly once.
+func (c *provider) GetManagedTransactor(ctx context.Context, keyId string, chainId *big.Int, nonces *NonceManager) (*bind.TransactOpts, error) {
+	opts, err := c.GetWalletTransactor(ctx, keyId, chainId)
+	if err != nil {
+		return nil, err
 	}
 
-	return &bind.TransactOpts{
-		From:   publicKeyAddress,
-		Signer: signerFn,
-	}, nil
+	nonce, err := nonces.Next(ctx, opts.From)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Nonce = new(big.Int).SetUint64(nonce)
+	return opts, nil
 }
 
-func (c *provider) GetWalletTransactorByAlias(ctx context.Context, alias string, chainId *big.Int) (*bind.TransactOpts, error) {
+func (c *provider) GetManagedTransactorByAlias(ctx context.Context, alias string, chainId *big.Int, nonces *NonceManager) (*bind.TransactOpts, error) {
 	keyId, err := c.GetKeyIdByAlias(ctx, alias)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.GetWalletTransactor(ctx, keyId, chainId)
+	return c.GetManagedTransactor(ctx, keyId, chainId, nonces)
 }
 
 func (c *provider) GetWalletCaller(ctx context.Context, keyId string, chainId *big.Int) (*bind.CallOpts, error) {
@@ -306,54 +445,121 @@ func (c *provider) GetWalletCallerByAlias(ctx context.Context, alias string, cha
 	return c.GetWalletCaller(ctx, keyId, chainId)
 }
 
-func (c *provider) SignMessage(ctx context.Context, keyId string, message []byte) ([]byte, error) {
+func (c *provider) SignMessage(ctx context.Context, keyId string, message []byte, opts ...SignOption) ([]byte, error) {
 	hashedMessage := toEthSignedMessageHash(message)
+	c.logger.DebugContext(ctx, "signing message", slog.String("keyId", keyId), c.messageAttr(message))
 
-	rBytes, sBytes, err := c.getSignatureFromKms(ctx, keyId, hashedMessage)
+	signature, err := c.signDigest(ctx, keyId, hashedMessage)
 	if err != nil {
 		return nil, err
 	}
 
-	sBigInt := new(big.Int).SetBytes(sBytes)
-	if sBigInt.Cmp(secp256k1HalfN) > 0 {
-		sBytes = new(big.Int).Sub(secp256k1N, sBigInt).Bytes()
+	return formatSignature(signature, opts), nil
+}
+
+// SignHash signs the 32 bytes hash as is, without the message prefix of SignMessage, and returns the 65 bytes
+// [R || S || V] signature with V as 27 or 28, or the compact signature with WithCompactSignature.
+func (c *provider) SignHash(ctx context.Context, keyId string, hash []byte, opts ...SignOption) ([]byte, error) {
+	if len(hash) != common.HashLength {
+		return nil, fmt.Errorf("hash is required to be exactly %d bytes (%d)", common.HashLength, len(hash))
 	}
 
-	publicKey, err := c.getPublicKey(ctx, keyId)
+	signature, err := c.signDigest(ctx, keyId, hash)
 	if err != nil {
 		return nil, err
 	}
 
-	publicKeyBytes := secp256k1.S256().Marshal(publicKey.X, publicKey.Y)
-	signature, err := c.getEthereumSignature(publicKeyBytes, hashedMessage, rBytes, sBytes)
+	return formatSignature(signature, opts), nil
+}
+
+func (c *provider) SignHashByAlias(ctx context.Context, alias string, hash []byte, opts ...SignOption) ([]byte, error) {
+	keyId, err := c.GetKeyIdByAlias(ctx, alias)
 	if err != nil {
 		return nil, err
 	}
 
-	signature[64] += 27
-	return signature, nil
+	return c.SignHash(ctx, keyId, hash, opts...)
+}
+
+// SignTransaction signs tx with the KMS key for the given signer, with the same low S and recovery id handling as
+// the transactors. It needs no bind.TransactOpts, so it fits raw transfers, relayers and offline signing.
+func (c *provider) SignTransaction(ctx context.Context, keyId string, signer ether_types.Signer, tx *ether_types.Transaction) (*ether_types.Transaction, error) {
+	return c.signTransaction(ctx, keyId, signer, tx)
+}
+
+func (c *provider) SignTransactionByAlias(ctx context.Context, alias string, signer ether_types.Signer, tx *ether_types.Transaction) (*ether_types.Transaction, error) {
+	keyId, err := c.GetKeyIdByAlias(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.SignTransaction(ctx, keyId, signer, tx)
+}
+
+// SignTransactionForChain signs tx with the latest signer for chainId. Blob transactions are signed with the Cancun
+// signer and keep their sidecar.
+func (c *provider) SignTransactionForChain(ctx context.Context, keyId string, chainId *big.Int, tx *ether_types.Transaction) (*ether_types.Transaction, error) {
+	if chainId == nil {
+		return nil, bind.ErrNoChainID
+	}
+
+	return c.signTransaction(ctx, keyId, transactionSigner(chainId, tx), tx)
 }
 
-func (c *provider) SignMessageByAlias(ctx context.Context, alias string, message []byte) ([]byte, error) {
+func (c *provider) SignMessageByAlias(ctx context.Context, alias string, message []byte, opts ...SignOption) ([]byte, error) {
 	keyId, err := c.GetKeyIdByAlias(ctx, alias)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.SignMessage(ctx, keyId, message)
+	return c.SignMessage(ctx, keyId, message, opts...)
 }
 
+// GetKeyIdByAlias returns the keyId alias points to. With WithAddressPinStore, the key has to derive the pinned
+// address of alias.
 func (c *provider) GetKeyIdByAlias(ctx context.Context, alias string) (keyId string, err error) {
-	prefixedAlias := getPrefixedAlias(alias)
-	output, err := c.client.DescribeKey(ctx, &kms.DescribeKeyInput{
-		KeyId: &prefixedAlias,
-	})
+	keyId, err = c.resolveAlias(ctx, alias)
+	if err != nil {
+		return keyId, err
+	}
+
+	if err := c.checkAliasPin(ctx, alias, keyId); err != nil {
+		return "", err
+	}
+
+	return keyId, nil
+}
 
+func (c *provider) signTransaction(ctx context.Context, keyId string, signer ether_types.Signer, tx *ether_types.Transaction) (*ether_types.Transaction, error) {
+	signature, err := c.signDigest(ctx, keyId, signer.Hash(tx).Bytes())
 	if err != nil {
-		return keyId, fmt.Errorf("can not get public key from KMS for alias: %s, err: %+v", alias, err)
+		return nil, err
 	}
 
-	return *output.KeyMetadata.KeyId, err
+	return tx.WithSignature(signer, signature)
+}
+
+// signDigest signs the digest with KMS and returns the 65 bytes [R || S || V] signature with a low S value and
+// V as the recovery id (0 or 1).
+func (c *provider) signDigest(ctx context.Context, keyId string, digest []byte) ([]byte, error) {
+	publicKey, err := c.getPublicKey(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	rBytes, sBytes, err := c.getSignatureFromKms(ctx, keyId, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Adjust S value from signature according to Ethereum standard
+	sBigInt := new(big.Int).SetBytes(sBytes)
+	if sBigInt.Cmp(secp256k1HalfN) > 0 {
+		sBytes = new(big.Int).Sub(secp256k1N, sBigInt).Bytes()
+	}
+
+	publicKeyBytes := secp256k1.S256().Marshal(publicKey.X, publicKey.Y)
+	return c.getEthereumSignature(ctx, keyId, publicKeyBytes, digest, rBytes, sBytes)
 }
 
 func (c *provider) getSignatureFromKms(
@@ -366,8 +572,15 @@ func (c *provider) getSignatureFromKms(
 		Message:          txHashBytes,
 	}
 
+	if c.signTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.signTimeout)
+		defer cancel()
+	}
+
 	signOutput, err := c.client.Sign(ctx, signInput)
 	if err != nil {
+		c.logger.ErrorContext(ctx, "can not sign digest with KMS", slog.String("keyId", keyId), slog.Any("err", err))
 		return nil, nil, err
 	}
 
@@ -380,23 +593,34 @@ func (c *provider) getSignatureFromKms(
 	return sigAsn1.R.Bytes, sigAsn1.S.Bytes, nil
 }
 
-func (c *provider) getEthereumSignature(expectedPublicKeyBytes []byte, txHash []byte, r []byte, s []byte) ([]byte, error) {
+func (c *provider) getEthereumSignature(ctx context.Context, keyId string, expectedPublicKeyBytes []byte, txHash []byte, r []byte, s []byte) ([]byte, error) {
 	rsSignature := append(adjustSignatureLength(r), adjustSignatureLength(s)...)
 	signature := append(rsSignature, []byte{0}...)
 
 	recoveredPublicKeyBytes, err := crypto.Ecrecover(txHash, signature)
 	if err != nil {
+		c.logger.ErrorContext(ctx, "can not recover public key from sig", slog.String("keyId", keyId), slog.String("digest", hex.EncodeToString(txHash)), slog.Any("err", err))
 		return nil, err
 	}
 
 	if hex.EncodeToString(recoveredPublicKeyBytes) != hex.EncodeToString(expectedPublicKeyBytes) {
+		c.metrics.IncRecoveryIdRetry()
+		c.logger.DebugContext(ctx, "retrying signature recovery with recovery id 1", slog.String("keyId", keyId))
 		signature = append(rsSignature, []byte{1}...)
 		recoveredPublicKeyBytes, err = crypto.Ecrecover(txHash, signature)
 		if err != nil {
+			c.logger.ErrorContext(ctx, "can not recover public key from sig", slog.String("keyId", keyId), slog.String("digest", hex.EncodeToString(txHash)), slog.Any("err", err))
 			return nil, err
 		}
 
 		if hex.EncodeToString(recoveredPublicKeyBytes) != hex.EncodeToString(expectedPublicKeyBytes) {
+			c.logger.ErrorContext(ctx, "can not reconstruct public key from sig",
+				slog.String("keyId", keyId),
+				slog.String("digest", hex.EncodeToString(txHash)),
+				slog.String("expectedPublicKey", hex.EncodeToString(expectedPublicKeyBytes)),
+				slog.String("r", hex.EncodeToString(r)),
+				slog.String("s", hex.EncodeToString(s)),
+			)
 			return nil, errors.New("can not reconstruct public key from sig")
 		}
 	}
@@ -408,10 +632,14 @@ func (c *provider) getPublicKey(ctx context.Context, keyId string) (*ecdsa.Publi
 	cacheKey := fmt.Sprintf(publicKeyCacheKey, keyId)
 	foundPublicKey, found := c.cache.Get(cacheKey)
 	if found {
+		c.metrics.IncPublicKeyCacheHit()
+		c.logger.DebugContext(ctx, "public key cache hit", slog.String("keyId", keyId))
 		publicKey := foundPublicKey.(ecdsa.PublicKey)
 		return &publicKey, nil
 	}
 
+	c.metrics.IncPublicKeyCacheMiss()
+	c.logger.DebugContext(ctx, "public key cache miss", slog.String("keyId", keyId))
 	publicKeyBytes, err := c.getPublicKeyBytes(ctx, keyId)
 	if err != nil {
 		return nil, err
@@ -432,7 +660,7 @@ func (c *provider) getPublicKeyBytes(ctx context.Context, keyId string) ([]byte,
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("can not get public key from KMS for keyId: %s, err: %+v", keyId, err)
+		return nil, fmt.Errorf("can not get public key from KMS for keyId: %s, err: %w", keyId, err)
 	}
 
 	var asn1pubk asn1EcPublicKey
@@ -444,6 +672,16 @@ func (c *provider) getPublicKeyBytes(ctx context.Context, keyId string) ([]byte,
 	return asn1pubk.PublicKey.Bytes, nil
 }
 
+// transactionSigner returns the signer for tx. Blob transactions always use the Cancun signer, which is the first
+// signer that accepts them.
+func transactionSigner(chainId *big.Int, tx *ether_types.Transaction) ether_types.Signer {
+	if tx.Type() == ether_types.BlobTxType {
+		return ether_types.NewCancunSigner(chainId)
+	}
+
+	return ether_types.LatestSignerForChainID(chainId)
+}
+
 func getPrefixedAlias(alias string) string {
 	return fmt.Sprintf("alias/%s", alias)
 }
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -58,6 +60,32 @@ func (m *mockKMSClient) GetPublicKey(ctx context.Context, params *kms.GetPublicK
 	return args.Get(0).(*kms.GetPublicKeyOutput), args.Error(1)
 }
 
+func (m *mockKMSClient) Verify(ctx context.Context, params *kms.VerifyInput, optFns ...func(*kms.Options)) (*kms.VerifyOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*kms.VerifyOutput), args.Error(1)
+}
+
+func (m *mockKMSClient) ScheduleKeyDeletion(ctx context.Context, params *kms.ScheduleKeyDeletionInput, optFns ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*kms.ScheduleKeyDeletionOutput), args.Error(1)
+}
+
+func (m *mockKMSClient) ListKeys(ctx context.Context, params *kms.ListKeysInput, optFns ...func(*kms.Options)) (*kms.ListKeysOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*kms.ListKeysOutput), args.Error(1)
+}
+
+func (m *mockKMSClient) ListAliases(ctx context.Context, params *kms.ListAliasesInput, optFns ...func(*kms.Options)) (*kms.ListAliasesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	return args.Get(0).(*kms.ListAliasesOutput), args.Error(1)
+}
+
+// newTestKMSClient returns an in-memory KMS client holding a single key, and the keyId of that key.
+func newTestKMSClient(t *testing.T) (*kmstest.Client, string) {
+	client := kmstest.NewClient()
+	return client, client.AddKey(t)
+}
+
 func TestCreateWallet_Should_Create_Wallet_With_Wallet_Address_Tag_When_Add_Wallet_Address_Tag_Is_True(t *testing.T) {
 	// given
 	mockClient := &mockKMSClient{}
@@ -429,6 +457,118 @@ func TestDisableWalletByAlias(t *testing.T) {
 	mockClient.AssertNumberOfCalls(t, "DisableKey", 1)
 }
 
+func TestScheduleWalletDeletion(t *testing.T) {
+	// given
+	mockClient := &mockKMSClient{}
+	provider := kmswallet.NewProvider(mockClient, nil)
+
+	mockClient.On("ScheduleKeyDeletion", mock.Anything, mock.Anything, mock.Anything).Return(&kms.ScheduleKeyDeletionOutput{}, nil)
+
+	// when
+	_, err := provider.ScheduleWalletDeletion(context.Background(), "keyId", 7)
+	_, defaultWindowErr := provider.ScheduleWalletDeletion(context.Background(), "keyId", 0)
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, defaultWindowErr)
+	mockClient.AssertCalled(t, "ScheduleKeyDeletion", mock.Anything, &kms.ScheduleKeyDeletionInput{KeyId: aws.String("keyId"), PendingWindowInDays: aws.Int32(7)}, mock.Anything)
+	mockClient.AssertCalled(t, "ScheduleKeyDeletion", mock.Anything, &kms.ScheduleKeyDeletionInput{KeyId: aws.String("keyId")}, mock.Anything)
+}
+
+func TestScheduleWalletDeletionByAlias(t *testing.T) {
+	// given
+	mockClient := &mockKMSClient{}
+	provider := kmswallet.NewProvider(mockClient, nil)
+	keyId := "keyId"
+
+	mockClient.On("DescribeKey", mock.Anything, mock.Anything, mock.Anything).Return(&kms.DescribeKeyOutput{
+		KeyMetadata: &types.KeyMetadata{
+			KeyId: &keyId,
+		},
+	}, nil)
+
+	mockClient.On("ScheduleKeyDeletion", mock.Anything, mock.Anything, mock.Anything).Return(&kms.ScheduleKeyDeletionOutput{}, nil)
+
+	// when
+	_, err := provider.ScheduleWalletDeletionByAlias(context.Background(), "alias", 30)
+
+	// then
+	assert.NoError(t, err)
+	mockClient.AssertCalled(t, "ScheduleKeyDeletion", mock.Anything, &kms.ScheduleKeyDeletionInput{KeyId: &keyId, PendingWindowInDays: aws.Int32(30)}, mock.Anything)
+}
+
+func TestListWallets_Should_Skip_Other_And_Denied_Keys_And_Page_Through_Keys(t *testing.T) {
+	// given
+	mockClient := &mockKMSClient{}
+	provider := kmswallet.NewProvider(mockClient, nil)
+
+	publicKey, _ := base64.StdEncoding.DecodeString("MFYwEAYHKoZIzj0CAQYFK4EEAAoDQgAERtrxsFyn7UzP2OgzzJA6Y89p/2175fOwXeP33ACZgmdD2jJlQdypNM9CCDm3J6uqTrvYrO0hwF8p/k/Tf94DjA==")
+	describeKey := func(keyId string, keySpec types.KeySpec, keyState types.KeyState) {
+		mockClient.On("DescribeKey", mock.Anything, &kms.DescribeKeyInput{KeyId: aws.String(keyId)}, mock.Anything).Return(&kms.DescribeKeyOutput{
+			KeyMetadata: &types.KeyMetadata{
+				KeyId:    aws.String(keyId),
+				KeySpec:  keySpec,
+				KeyUsage: types.KeyUsageTypeSignVerify,
+				KeyState: keyState,
+			},
+		}, nil)
+	}
+
+	describeKey("keyId", types.KeySpecEccSecgP256k1, types.KeyStateEnabled)
+	describeKey("rsaKeyId", types.KeySpecRsa2048, types.KeyStateEnabled)
+	describeKey("disabledKeyId", types.KeySpecEccSecgP256k1, types.KeyStateDisabled)
+	describeKey("unreadableKeyId", types.KeySpecEccSecgP256k1, types.KeyStateEnabled)
+	mockClient.On("DescribeKey", mock.Anything, &kms.DescribeKeyInput{KeyId: aws.String("deniedKeyId")}, mock.Anything).Return((*kms.DescribeKeyOutput)(nil), &smithy.GenericAPIError{
+		Code: "AccessDeniedException",
+	})
+
+	mockClient.On("ListAliases", mock.Anything, mock.Anything, mock.Anything).Return(&kms.ListAliasesOutput{
+		Aliases: []types.AliasListEntry{
+			{AliasName: aws.String("alias/treasury"), TargetKeyId: aws.String("keyId")},
+			{AliasName: aws.String("alias/aws/s3")},
+		},
+	}, nil)
+
+	mockClient.On("ListKeys", mock.Anything, &kms.ListKeysInput{}, mock.Anything).Return(&kms.ListKeysOutput{
+		Keys:       []types.KeyListEntry{{KeyId: aws.String("keyId")}, {KeyId: aws.String("rsaKeyId")}},
+		NextMarker: aws.String("marker"),
+		Truncated:  true,
+	}, nil).Once()
+
+	mockClient.On("ListKeys", mock.Anything, mock.Anything, mock.Anything).Return(&kms.ListKeysOutput{
+		Keys: []types.KeyListEntry{{KeyId: aws.String("deniedKeyId")}, {KeyId: aws.String("unreadableKeyId")}, {KeyId: aws.String("disabledKeyId")}},
+	}, nil).Once()
+
+	mockClient.On("GetPublicKey", mock.Anything, &kms.GetPublicKeyInput{KeyId: aws.String("keyId")}, mock.Anything).Return(&kms.GetPublicKeyOutput{
+		PublicKey: publicKey,
+	}, nil)
+
+	mockClient.On("GetPublicKey", mock.Anything, &kms.GetPublicKeyInput{KeyId: aws.String("disabledKeyId")}, mock.Anything).Return((*kms.GetPublicKeyOutput)(nil), &types.DisabledException{})
+	mockClient.On("GetPublicKey", mock.Anything, &kms.GetPublicKeyInput{KeyId: aws.String("unreadableKeyId")}, mock.Anything).Return((*kms.GetPublicKeyOutput)(nil), &smithy.GenericAPIError{
+		Code: "AccessDeniedException",
+	})
+
+	// when
+	wallets, err := provider.ListWallets(context.Background())
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []kmswallet.WalletDetails{
+		{
+			Address:  "0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118",
+			KeyId:    "keyId",
+			Aliases:  []string{"treasury"},
+			KeyState: types.KeyStateEnabled,
+		},
+		{
+			KeyId:    "disabledKeyId",
+			KeyState: types.KeyStateDisabled,
+		},
+	}, wallets)
+	mockClient.AssertNumberOfCalls(t, "ListKeys", 2)
+	mockClient.AssertNumberOfCalls(t, "GetPublicKey", 3)
+}
+
 func TestEnableWallet(t *testing.T) {
 	// given
 	mockClient := &mockKMSClient{}
@@ -0,0 +1,111 @@
+package kmswallet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"sort"
+	"sync"
+)
+
+var ErrQuorumNotReached = errors.New("quorum not reached")
+
+// QuorumWallet is an M-of-N wallet of KMS keys, e.g. the owners of an on-chain multisig.
+type QuorumWallet struct {
+	members   []WalletKey
+	threshold int
+}
+
+type QuorumSignature struct {
+	Signer    common.Address
+	Signature []byte
+}
+
+type QuorumFailure struct {
+	Member WalletKey
+	Err    error
+}
+
+// QuorumResult holds the signatures of the members that signed, sorted by signer address, and the members that failed.
+type QuorumResult struct {
+	Signatures []QuorumSignature
+	Failures   []QuorumFailure
+}
+
+func NewQuorumWallet(threshold int, members ...WalletKey) (*QuorumWallet, error) {
+	if threshold < 1 || threshold > len(members) {
+		return nil, fmt.Errorf("quorum threshold is required to be between 1 and %d (%d)", len(members), threshold)
+	}
+
+	return &QuorumWallet{
+		members:   members,
+		threshold: threshold,
+	}, nil
+}
+
+// SignHash signs hash with every member in parallel. The result is returned even when the quorum is not reached,
+// together with ErrQuorumNotReached, so the failed members can be reported.
+func (q *QuorumWallet) SignHash(ctx context.Context, hash []byte) (*QuorumResult, error) {
+	if len(hash) != common.HashLength {
+		return nil, fmt.Errorf("hash is required to be exactly %d bytes (%d)", common.HashLength, len(hash))
+	}
+
+	signatures := make([]QuorumSignature, len(q.members))
+	errs := make([]error, len(q.members))
+	var wg sync.WaitGroup
+	for i, member := range q.members {
+		wg.Add(1)
+		go func(i int, member WalletKey) {
+			defer wg.Done()
+			signature, err := member.Provider.SignHash(ctx, member.KeyId, hash)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			signer, err := RecoverAddress(hash, signature)
+			signatures[i] = QuorumSignature{Signer: signer, Signature: signature}
+			errs[i] = err
+		}(i, member)
+	}
+
+	wg.Wait()
+
+	result := &QuorumResult{}
+	signers := make(map[common.Address]bool)
+	for i, member := range q.members {
+		if errs[i] == nil && signers[signatures[i].Signer] {
+			errs[i] = fmt.Errorf("duplicate quorum signer: %s", signatures[i].Signer)
+		}
+
+		if errs[i] != nil {
+			result.Failures = append(result.Failures, QuorumFailure{Member: member, Err: errs[i]})
+			continue
+		}
+
+		signers[signatures[i].Signer] = true
+		result.Signatures = append(result.Signatures, signatures[i])
+	}
+
+	sort.Slice(result.Signatures, func(i, j int) bool {
+		return bytes.Compare(result.Signatures[i].Signer.Bytes(), result.Signatures[j].Signer.Bytes()) < 0
+	})
+
+	if len(result.Signatures) < q.threshold {
+		return result, fmt.Errorf("%w: %d of %d members signed, threshold is %d", ErrQuorumNotReached, len(result.Signatures), len(q.members), q.threshold)
+	}
+
+	return result, nil
+}
+
+// Bytes returns the signatures concatenated in signer address order.
+func (r *QuorumResult) Bytes() []byte {
+	joined := make([]byte, 0, len(r.Signatures)*65)
+	for _, signature := range r.Signatures {
+		joined = append(joined, signature.Signature...)
+	}
+
+	return joined
+}
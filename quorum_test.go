@@ -0,0 +1,114 @@
+package kmswallet_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newQuorumMembers(t *testing.T, count int) ([]kmswallet.WalletKey, []*kmstest.Client) {
+	members := make([]kmswallet.WalletKey, count)
+	clients := make([]*kmstest.Client, count)
+	for i := range members {
+		var keyId string
+		clients[i], keyId = newTestKMSClient(t)
+		members[i] = kmswallet.WalletKey{Provider: kmswallet.NewProvider(clients[i], nil), KeyId: keyId}
+	}
+
+	return members, clients
+}
+
+func TestNewQuorumWallet_Should_Reject_Invalid_Threshold(t *testing.T) {
+	members, _ := newQuorumMembers(t, 2)
+	for _, threshold := range []int{0, 3} {
+		// when
+		wallet, err := kmswallet.NewQuorumWallet(threshold, members...)
+
+		// then
+		assert.Error(t, err)
+		assert.Nil(t, wallet)
+	}
+}
+
+func TestQuorumWallet_SignHash(t *testing.T) {
+	// given
+	members, _ := newQuorumMembers(t, 3)
+	wallet, _ := kmswallet.NewQuorumWallet(3, members...)
+	hash := crypto.Keccak256([]byte("operation"))
+
+	// when
+	result, err := wallet.SignHash(context.Background(), hash)
+
+	// then
+	assert.NoError(t, err)
+	assert.Empty(t, result.Failures)
+	assert.Len(t, result.Signatures, 3)
+	for i, signature := range result.Signatures {
+		signer, err := kmswallet.RecoverAddress(hash, signature.Signature)
+		assert.NoError(t, err)
+		assert.Equal(t, signature.Signer, signer)
+		if i > 0 {
+			assert.Equal(t, -1, bytes.Compare(result.Signatures[i-1].Signer.Bytes(), signer.Bytes()))
+		}
+	}
+	assert.Equal(t, append(append(append([]byte{}, result.Signatures[0].Signature...), result.Signatures[1].Signature...), result.Signatures[2].Signature...), result.Bytes())
+}
+
+func TestQuorumWallet_SignHash_Should_Report_Failed_Members_When_Threshold_Is_Reached(t *testing.T) {
+	// given
+	members, clients := newQuorumMembers(t, 3)
+	clients[1].SetSignHook(func(context.Context, []byte) error {
+		return errors.New("region unavailable")
+	})
+
+	wallet, _ := kmswallet.NewQuorumWallet(2, members...)
+
+	// when
+	result, err := wallet.SignHash(context.Background(), crypto.Keccak256([]byte("operation")))
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, result.Signatures, 2)
+	assert.Len(t, result.Failures, 1)
+	assert.Equal(t, members[1], result.Failures[0].Member)
+	assert.ErrorContains(t, result.Failures[0].Err, "region unavailable")
+}
+
+func TestQuorumWallet_SignHash_When_Quorum_Is_Not_Reached(t *testing.T) {
+	// given
+	members, clients := newQuorumMembers(t, 3)
+	for _, client := range clients[1:] {
+		client.SetSignHook(func(context.Context, []byte) error {
+			return errors.New("access denied")
+		})
+	}
+
+	wallet, _ := kmswallet.NewQuorumWallet(2, members...)
+
+	// when
+	result, err := wallet.SignHash(context.Background(), crypto.Keccak256([]byte("operation")))
+
+	// then
+	assert.ErrorIs(t, err, kmswallet.ErrQuorumNotReached)
+	assert.Len(t, result.Signatures, 1)
+	assert.Len(t, result.Failures, 2)
+}
+
+func TestQuorumWallet_SignHash_Should_Not_Count_Duplicate_Signers(t *testing.T) {
+	// given
+	members, _ := newQuorumMembers(t, 1)
+	wallet, _ := kmswallet.NewQuorumWallet(2, members[0], members[0])
+
+	// when
+	result, err := wallet.SignHash(context.Background(), crypto.Keccak256([]byte("operation")))
+
+	// then
+	assert.ErrorIs(t, err, kmswallet.ErrQuorumNotReached)
+	assert.Len(t, result.Signatures, 1)
+	assert.ErrorContains(t, result.Failures[0].Err, "duplicate quorum signer")
+}
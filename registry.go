@@ -0,0 +1,235 @@
+package kmswallet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ether_types "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gopkg.in/yaml.v3"
+	"log/slog"
+	"math/big"
+	"os"
+	"slices"
+	"sort"
+)
+
+var (
+	ErrUnknownWalletName = errors.New("unknown wallet name")
+	ErrPolicyViolation   = errors.New("wallet policy violation")
+)
+
+const (
+	PolicyReasonChainNotAllowed      = "ChainNotAllowed"
+	PolicyReasonMaxValueExceeded     = "MaxValueExceeded"
+	PolicyReasonMaxFeePerGasExceeded = "MaxFeePerGasExceeded"
+	PolicyReasonRecipientNotAllowed  = "RecipientNotAllowed"
+)
+
+// Registry maps logical wallet names to KMS keys:
+//
+//	wallets:
+//	  treasury:
+//	    alias: treasury
+//	    address: "0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118"
+//	    chains: [1, 10]
+//	    policy:
+//	      maxValue: 1000000000000000000
+//	      maxFeePerGas: 200000000000
+//	      allowedRecipients: ["0x1111111254EEB25477B68fb85Ed929f73A960582"]
+type Registry struct {
+	Wallets map[string]RegistryWallet `yaml:"wallets" json:"wallets"`
+}
+
+// RegistryWallet is a registry entry. Either KeyId or Alias identifies the key, and the key has to derive Address.
+// Empty Chains allows every chain.
+type RegistryWallet struct {
+	KeyId   string         `yaml:"keyId" json:"keyId"`
+	Alias   string         `yaml:"alias" json:"alias"`
+	Address common.Address `yaml:"address" json:"address"`
+	Chains  []uint64       `yaml:"chains" json:"chains"`
+	Policy  WalletPolicy   `yaml:"policy" json:"policy"`
+}
+
+// WalletPolicy limits the transactions signed by the transactors of GetTransactorByName. MaxFeePerGas applies to
+// the gas price of legacy transactions and the fee cap of EIP-1559 transactions. A non-empty AllowedRecipients also
+// rejects contract creations.
+//
+// Only GetTransactorByName enforces the policy and the chains of a wallet. Signing with the keyId of
+// GetKeyIdByName, for example with SignTransaction, bypasses them.
+type WalletPolicy struct {
+	MaxValue          *big.Int         `yaml:"maxValue" json:"maxValue"`
+	MaxFeePerGas      *big.Int         `yaml:"maxFeePerGas" json:"maxFeePerGas"`
+	AllowedRecipients []common.Address `yaml:"allowedRecipients" json:"allowedRecipients"`
+}
+
+// LoadRegistry reads a YAML or JSON registry file.
+func LoadRegistry(path string) (Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Registry{}, err
+	}
+
+	return ParseRegistry(data)
+}
+
+// ParseRegistry parses a YAML or JSON registry. Unknown fields are rejected so that typos do not silently drop a
+// policy.
+func ParseRegistry(data []byte) (Registry, error) {
+	var registry Registry
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&registry); err != nil {
+		return Registry{}, fmt.Errorf("can not parse wallet registry, err: %w", err)
+	}
+
+	for name, wallet := range registry.Wallets {
+		switch {
+		case (wallet.KeyId == "") == (wallet.Alias == ""):
+			return Registry{}, fmt.Errorf("wallet %s: exactly one of keyId and alias is required", name)
+		case wallet.Address == (common.Address{}):
+			return Registry{}, fmt.Errorf("wallet %s: address is required", name)
+		}
+	}
+
+	return registry, nil
+}
+
+// WithRegistry makes the wallets of registry available by name. Call ValidateRegistry at startup to check every
+// wallet before it is used; wallets are also validated on their first use.
+func WithRegistry(registry Registry) ProviderOption {
+	return func(p *provider) {
+		p.registry = registry
+	}
+}
+
+// ValidateRegistry checks that the key of every registry wallet exists, is enabled and derives the expected address.
+// All failures are returned together.
+func (c *provider) ValidateRegistry(ctx context.Context) error {
+	names := make([]string, 0, len(c.registry.Wallets))
+	for name := range c.registry.Wallets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		if _, err := c.GetKeyIdByName(ctx, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetKeyIdByName returns the keyId of the registry wallet name, validating the wallet on its first use. The keyId
+// signs without the chains and policy of the wallet; use GetTransactorByName to enforce them.
+func (c *provider) GetKeyIdByName(ctx context.Context, name string) (string, error) {
+	wallet, ok := c.registry.Wallets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownWalletName, name)
+	}
+
+	c.registryMu.Lock()
+	keyId, ok := c.registryKeyIds[name]
+	c.registryMu.Unlock()
+	if ok {
+		return keyId, nil
+	}
+
+	keyId, err := c.validateRegistryWallet(ctx, name, wallet)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "invalid registry wallet", slog.String("name", name), slog.Any("err", err))
+		return "", err
+	}
+
+	c.registryMu.Lock()
+	c.registryKeyIds[name] = keyId
+	c.registryMu.Unlock()
+
+	c.logger.DebugContext(ctx, "validated registry wallet", slog.String("name", name), slog.String("keyId", keyId))
+	return keyId, nil
+}
+
+// GetTransactorByName returns a transactor of the registry wallet name. chainId has to be one of the chains of the
+// wallet, and is required when the wallet lists chains. Transactions that break its policy are refused with
+// ErrPolicyViolation.
+func (c *provider) GetTransactorByName(ctx context.Context, name string, chainId *big.Int) (*bind.TransactOpts, error) {
+	keyId, err := c.GetKeyIdByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet := c.registry.Wallets[name]
+	if chainId == nil && len(wallet.Chains) > 0 {
+		return nil, c.policyViolation(ctx, "GetTransactorByName", name, PolicyReasonChainNotAllowed, "a chain id is required")
+	}
+
+	if chainId != nil && len(wallet.Chains) > 0 && !slices.ContainsFunc(wallet.Chains, func(chain uint64) bool {
+		return new(big.Int).SetUint64(chain).Cmp(chainId) == 0
+	}) {
+		return nil, c.policyViolation(ctx, "GetTransactorByName", name, PolicyReasonChainNotAllowed, fmt.Sprintf("chain %s is not allowed", chainId))
+	}
+
+	return c.newTransactor(ctx, keyId, chainId, func(tx *ether_types.Transaction) error {
+		return c.checkWalletPolicy(ctx, name, wallet.Policy, tx)
+	})
+}
+
+func (c *provider) validateRegistryWallet(ctx context.Context, name string, wallet RegistryWallet) (string, error) {
+	keyId := wallet.KeyId
+	if wallet.Alias != "" {
+		var err error
+		if keyId, err = c.resolveAlias(ctx, wallet.Alias); err != nil {
+			return "", fmt.Errorf("wallet %s: %w", name, err)
+		}
+	}
+
+	output, err := c.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: &keyId})
+	if err != nil {
+		return "", fmt.Errorf("wallet %s: can not describe key %s, err: %w", name, keyId, err)
+	}
+
+	if output.KeyMetadata.KeyState != types.KeyStateEnabled {
+		return "", fmt.Errorf("wallet %s: key %s is %s", name, keyId, output.KeyMetadata.KeyState)
+	}
+
+	if output.KeyMetadata.KeySpec != types.KeySpecEccSecgP256k1 {
+		return "", fmt.Errorf("wallet %s: key %s is not a secp256k1 key", name, keyId)
+	}
+
+	publicKey, err := c.getPublicKey(ctx, keyId)
+	if err != nil {
+		return "", fmt.Errorf("wallet %s: %w", name, err)
+	}
+
+	if address := crypto.PubkeyToAddress(*publicKey); address != wallet.Address {
+		return "", fmt.Errorf("%w: wallet %s: key %s derives %s, expected %s", ErrAddressMismatch, name, keyId, address, wallet.Address)
+	}
+
+	return keyId, nil
+}
+
+func (c *provider) checkWalletPolicy(ctx context.Context, name string, policy WalletPolicy, tx *ether_types.Transaction) error {
+	switch {
+	case policy.MaxValue != nil && tx.Value().Cmp(policy.MaxValue) > 0:
+		return c.policyViolation(ctx, "SignTransaction", name, PolicyReasonMaxValueExceeded, fmt.Sprintf("value %s exceeds %s", tx.Value(), policy.MaxValue))
+	case policy.MaxFeePerGas != nil && tx.GasFeeCap().Cmp(policy.MaxFeePerGas) > 0:
+		return c.policyViolation(ctx, "SignTransaction", name, PolicyReasonMaxFeePerGasExceeded, fmt.Sprintf("fee per gas %s exceeds %s", tx.GasFeeCap(), policy.MaxFeePerGas))
+	case len(policy.AllowedRecipients) > 0 && (tx.To() == nil || !slices.Contains(policy.AllowedRecipients, *tx.To())):
+		return c.policyViolation(ctx, "SignTransaction", name, PolicyReasonRecipientNotAllowed, "recipient is not allowed")
+	}
+
+	return nil
+}
+
+func (c *provider) policyViolation(ctx context.Context, operation string, name string, reason string, message string) error {
+	c.metrics.IncPolicyRejection(operation, reason)
+	c.logger.WarnContext(ctx, "refused by wallet policy", slog.String("name", name), slog.String("reason", reason), slog.String("message", message))
+	return fmt.Errorf("%w: wallet %s: %s", ErrPolicyViolation, name, message)
+}
@@ -0,0 +1,180 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/common"
+	ether_types "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+var testRecipient = common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+
+func newRegistryKMSClient(t *testing.T, keyState types.KeyState) (*kmstest.Client, string) {
+	client, keyId := newTestKMSClient(t)
+	client.AddAlias("treasury", keyId)
+	if keyState == types.KeyStateDisabled {
+		if _, err := client.DisableKey(context.Background(), &kms.DisableKeyInput{KeyId: &keyId}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return client, keyId
+}
+
+func testRegistry(address common.Address) kmswallet.Registry {
+	return kmswallet.Registry{Wallets: map[string]kmswallet.RegistryWallet{
+		"treasury": {
+			Alias:   "treasury",
+			Address: address,
+			Chains:  []uint64{1, 10},
+			Policy: kmswallet.WalletPolicy{
+				MaxValue:          big.NewInt(1e18),
+				MaxFeePerGas:      big.NewInt(100e9),
+				AllowedRecipients: []common.Address{testRecipient},
+			},
+		},
+	}}
+}
+
+func TestParseRegistry_Should_Parse_YAML_And_JSON(t *testing.T) {
+	// given
+	yamlRegistry := `
+wallets:
+  treasury:
+    alias: treasury
+    address: "0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118"
+    chains: [1, 10]
+    policy:
+      maxValue: 1000000000000000000
+      maxFeePerGas: "0x174876e800"
+      allowedRecipients: ["0x1111111254EEB25477B68fb85Ed929f73A960582"]
+  payouts:
+    keyId: 1234abcd-12ab-34cd-56ef-1234567890ab
+    address: "0x1111111254EEB25477B68fb85Ed929f73A960582"
+`
+	jsonRegistry := `{"wallets": {"treasury": {
+		"alias": "treasury",
+		"address": "0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118",
+		"chains": [1, 10],
+		"policy": {"maxValue": 1000000000000000000, "maxFeePerGas": "100000000000", "allowedRecipients": ["0x1111111254EEB25477B68fb85Ed929f73A960582"]}
+	}}}`
+
+	for name, data := range map[string]string{"yaml": yamlRegistry, "json": jsonRegistry} {
+		// when
+		registry, err := kmswallet.ParseRegistry([]byte(data))
+
+		// then
+		assert.NoError(t, err, name)
+		assert.Equal(t, testRegistry(common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")).Wallets["treasury"], registry.Wallets["treasury"], name)
+	}
+}
+
+func TestParseRegistry_When_Registry_Is_Invalid(t *testing.T) {
+	// given
+	registries := map[string]string{
+		"wallet payouts: exactly one of keyId and alias is required": `{"wallets": {"payouts": {"address": "0x1111111254EEB25477B68fb85Ed929f73A960582"}}}`,
+		"wallet payouts: address is required":                        `{"wallets": {"payouts": {"alias": "payouts"}}}`,
+		"field maxValu not found":                                    `{"wallets": {"payouts": {"alias": "payouts", "policy": {"maxValu": 1}}}}`,
+	}
+
+	for expectedErr, data := range registries {
+		// when
+		_, err := kmswallet.ParseRegistry([]byte(data))
+
+		// then
+		assert.ErrorContains(t, err, expectedErr)
+	}
+}
+
+func TestValidateRegistry(t *testing.T) {
+	// given
+	client, keyId := newRegistryKMSClient(t, types.KeyStateEnabled)
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithRegistry(testRegistry(client.Address(keyId))))
+
+	// when
+	err := provider.ValidateRegistry(context.Background())
+	resolvedKeyId, keyIdErr := provider.GetKeyIdByName(context.Background(), "treasury")
+	_, unknownErr := provider.GetKeyIdByName(context.Background(), "payouts")
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, keyIdErr)
+	assert.Equal(t, keyId, resolvedKeyId)
+	assert.ErrorIs(t, unknownErr, kmswallet.ErrUnknownWalletName)
+}
+
+func TestValidateRegistry_When_Address_Does_Not_Match(t *testing.T) {
+	// given
+	client, keyId := newRegistryKMSClient(t, types.KeyStateEnabled)
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithRegistry(testRegistry(testRecipient)))
+
+	// when
+	err := provider.ValidateRegistry(context.Background())
+
+	// then
+	assert.ErrorIs(t, err, kmswallet.ErrAddressMismatch)
+	assert.ErrorContains(t, err, "wallet treasury: key "+keyId+" derives "+client.Address(keyId).Hex()+", expected "+testRecipient.Hex())
+}
+
+func TestValidateRegistry_When_Key_Is_Disabled(t *testing.T) {
+	// given
+	client, keyId := newRegistryKMSClient(t, types.KeyStateDisabled)
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithRegistry(testRegistry(client.Address(keyId))))
+
+	// when
+	err := provider.ValidateRegistry(context.Background())
+	_, transactorErr := provider.GetTransactorByName(context.Background(), "treasury", big.NewInt(1))
+
+	// then
+	assert.ErrorContains(t, err, "wallet treasury: key "+keyId+" is Disabled")
+	assert.ErrorContains(t, transactorErr, "wallet treasury: key "+keyId+" is Disabled")
+}
+
+func TestGetTransactorByName_Should_Enforce_Wallet_Policy(t *testing.T) {
+	// given
+	client, keyId := newRegistryKMSClient(t, types.KeyStateEnabled)
+	metrics := newRecordingMetrics()
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithMetrics(metrics), kmswallet.WithRegistry(testRegistry(client.Address(keyId))))
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	newTx := func(to *common.Address, value int64, feeCap int64) *ether_types.Transaction {
+		return ether_types.NewTx(&ether_types.DynamicFeeTx{ChainID: big.NewInt(10), GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(feeCap), Gas: 21000, To: to, Value: big.NewInt(value)})
+	}
+
+	// when
+	opts, err := provider.GetTransactorByName(context.Background(), "treasury", big.NewInt(10))
+	_, chainErr := provider.GetTransactorByName(context.Background(), "treasury", big.NewInt(137))
+	_, nilChainErr := provider.GetTransactorByName(context.Background(), "treasury", nil)
+
+	signedTx, signErr := opts.Signer(opts.From, newTx(&testRecipient, 1e18, 100e9))
+	_, valueErr := opts.Signer(opts.From, newTx(&testRecipient, 2e18, 100e9))
+	_, feeErr := opts.Signer(opts.From, newTx(&testRecipient, 1, 101e9))
+	_, recipientErr := opts.Signer(opts.From, newTx(&other, 1, 1e9))
+	_, creationErr := opts.Signer(opts.From, newTx(nil, 0, 1e9))
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, signErr)
+	assert.NotNil(t, signedTx)
+
+	for _, policyErr := range []error{chainErr, nilChainErr, valueErr, feeErr, recipientErr, creationErr} {
+		assert.ErrorIs(t, policyErr, kmswallet.ErrPolicyViolation)
+	}
+
+	assert.ErrorContains(t, chainErr, "chain 137 is not allowed")
+	assert.ErrorContains(t, nilChainErr, "a chain id is required")
+	assert.ErrorContains(t, valueErr, "value 2000000000000000000 exceeds 1000000000000000000")
+	assert.Equal(t, map[string]int{
+		"GetTransactorByName:ChainNotAllowed":  2,
+		"SignTransaction:MaxValueExceeded":     1,
+		"SignTransaction:MaxFeePerGasExceeded": 1,
+		"SignTransaction:RecipientNotAllowed":  2,
+	}, metrics.policyRejections)
+	assert.Equal(t, 1, client.SignCalls())
+}
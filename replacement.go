@@ -0,0 +1,311 @@
+package kmswallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	ether_types "github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"time"
+)
+
+const (
+	// MinReplacementBump is the minimum fee bump, in percent, that nodes accept for a same-nonce replacement.
+	MinReplacementBump = 10
+	cancelGasLimit     = 21000
+)
+
+var (
+	ErrTransactionNotPending   = errors.New("transaction is not pending")
+	ErrReplacementNotSupported = errors.New("replacement is not supported for the transaction type")
+)
+
+type replacementGroup struct {
+	original  common.Hash
+	txs       []common.Hash
+	cancelled map[common.Hash]bool
+}
+
+type ReplacementResult struct {
+	Receipt *ether_types.Receipt
+	// Replaced is true when a replacement was mined instead of the original transaction.
+	Replaced bool
+	// Cancelled is true when the mined transaction is a cancellation sent by Cancel.
+	Cancelled bool
+}
+
+// SpeedUp re-signs the pending transaction with the same nonce and its fees bumped by bump percent.
+// Bumps below MinReplacementBump are raised to it, and the fees never go below the backend's current suggestion.
+func (s *Sender) SpeedUp(ctx context.Context, keyId string, txHash common.Hash, bump uint64) (*ether_types.Transaction, error) {
+	tx, err := s.pendingTransaction(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, err := s.bumpedTransaction(ctx, tx, tx.To(), tx.Value(), tx.Data(), tx.Gas(), bump)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, err := s.sendReplacement(ctx, keyId, tx, replacement)
+	if err != nil {
+		return nil, err
+	}
+
+	s.trackReplacement(txHash, signedTx.Hash(), false)
+	return signedTx, nil
+}
+
+// Cancel replaces the pending transaction with a zero value transfer to the wallet itself, with the same nonce
+// and the minimum replacement fee bump.
+func (s *Sender) Cancel(ctx context.Context, keyId string, txHash common.Hash) (*ether_types.Transaction, error) {
+	tx, err := s.pendingTransaction(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := ether_types.Sender(ether_types.LatestSignerForChainID(s.chainId), tx)
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, err := s.bumpedTransaction(ctx, tx, &from, new(big.Int), nil, cancelGasLimit, MinReplacementBump)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, err := s.sendReplacement(ctx, keyId, tx, replacement)
+	if err != nil {
+		return nil, err
+	}
+
+	s.trackReplacement(txHash, signedTx.Hash(), true)
+	return signedTx, nil
+}
+
+// WaitForAny waits until the transaction or any of its replacements sent through this sender is mined and has the
+// given number of confirmations, and reports which one it was. The replacements are forgotten once a receipt is
+// returned.
+func (s *Sender) WaitForAny(ctx context.Context, txHash common.Hash, confirmations uint64) (*ReplacementResult, error) {
+	if confirmations == 0 {
+		confirmations = 1
+	}
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		original, txs, cancelled := s.replacementCandidates(txHash)
+		for _, candidate := range txs {
+			receipt, err := s.confirmedReceipt(ctx, candidate, confirmations)
+			if err != nil {
+				return nil, err
+			}
+
+			if receipt == nil {
+				continue
+			}
+
+			s.ForgetReplacements(original)
+			result := &ReplacementResult{
+				Receipt:   receipt,
+				Replaced:  candidate != original,
+				Cancelled: cancelled[candidate],
+			}
+
+			if receipt.Status == ether_types.ReceiptStatusFailed {
+				return result, ErrTransactionFailed
+			}
+
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sender) pendingTransaction(ctx context.Context, txHash common.Hash) (*ether_types.Transaction, error) {
+	tx, isPending, err := s.backend.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isPending {
+		return nil, fmt.Errorf("%w: %s", ErrTransactionNotPending, txHash)
+	}
+
+	// Blob transactions can only be replaced by blob transactions carrying the blobs, which the backend does not
+	// return, and later types carry data a dynamic fee replacement would drop.
+	switch tx.Type() {
+	case ether_types.LegacyTxType, ether_types.AccessListTxType, ether_types.DynamicFeeTxType:
+		return tx, nil
+	default:
+		return nil, fmt.Errorf("%w: type %d", ErrReplacementNotSupported, tx.Type())
+	}
+}
+
+func (s *Sender) bumpedTransaction(
+	ctx context.Context, tx *ether_types.Transaction, to *common.Address, value *big.Int, data []byte, gas uint64, bump uint64,
+) (*ether_types.Transaction, error) {
+	if bump < MinReplacementBump {
+		bump = MinReplacementBump
+	}
+
+	if tx.Type() == ether_types.LegacyTxType || tx.Type() == ether_types.AccessListTxType {
+		suggestedGasPrice, err := s.backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		gasPrice := maxBigInt(bumpFee(tx.GasPrice(), bump), suggestedGasPrice)
+		if tx.Type() == ether_types.AccessListTxType {
+			return ether_types.NewTx(&ether_types.AccessListTx{
+				ChainID:    s.chainId,
+				Nonce:      tx.Nonce(),
+				GasPrice:   gasPrice,
+				Gas:        gas,
+				To:         to,
+				Value:      value,
+				Data:       data,
+				AccessList: tx.AccessList(),
+			}), nil
+		}
+
+		return ether_types.NewTx(&ether_types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: gasPrice,
+			Gas:      gas,
+			To:       to,
+			Value:    value,
+			Data:     data,
+		}), nil
+	}
+
+	suggestedGasTipCap, err := s.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := s.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gasTipCap := maxBigInt(bumpFee(tx.GasTipCap(), bump), suggestedGasTipCap)
+	gasFeeCap := bumpFee(tx.GasFeeCap(), bump)
+	if head.BaseFee != nil {
+		gasFeeCap = maxBigInt(gasFeeCap, new(big.Int).Add(gasTipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2))))
+	}
+
+	return ether_types.NewTx(&ether_types.DynamicFeeTx{
+		ChainID:    s.chainId,
+		Nonce:      tx.Nonce(),
+		GasTipCap:  gasTipCap,
+		GasFeeCap:  maxBigInt(gasFeeCap, gasTipCap),
+		Gas:        gas,
+		To:         to,
+		Value:      value,
+		Data:       data,
+		AccessList: tx.AccessList(),
+	}), nil
+}
+
+func (s *Sender) sendReplacement(ctx context.Context, keyId string, original *ether_types.Transaction, replacement *ether_types.Transaction) (*ether_types.Transaction, error) {
+	opts, err := s.provider.GetWalletTransactor(ctx, keyId, s.chainId)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := ether_types.Sender(ether_types.LatestSignerForChainID(s.chainId), original)
+	if err != nil {
+		return nil, err
+	}
+
+	if from != opts.From {
+		return nil, fmt.Errorf("transaction %s is sent by %s, not by the wallet of keyId: %s", original.Hash(), from, keyId)
+	}
+
+	opts.Context = ctx
+	signedTx, err := opts.Signer(opts.From, replacement)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.backend.SendTransaction(ctx, signedTx); err != nil {
+		return nil, err
+	}
+
+	return signedTx, nil
+}
+
+func (s *Sender) trackReplacement(txHash common.Hash, replacementHash common.Hash, cancelled bool) {
+	s.replacementsMu.Lock()
+	defer s.replacementsMu.Unlock()
+
+	group, ok := s.replacements[txHash]
+	if !ok {
+		group = &replacementGroup{
+			original:  txHash,
+			txs:       []common.Hash{txHash},
+			cancelled: map[common.Hash]bool{},
+		}
+		s.replacements[txHash] = group
+	}
+
+	group.txs = append(group.txs, replacementHash)
+	group.cancelled[replacementHash] = cancelled
+	s.replacements[replacementHash] = group
+}
+
+func (s *Sender) replacementCandidates(txHash common.Hash) (common.Hash, []common.Hash, map[common.Hash]bool) {
+	s.replacementsMu.Lock()
+	defer s.replacementsMu.Unlock()
+
+	group, ok := s.replacements[txHash]
+	if !ok {
+		return txHash, []common.Hash{txHash}, nil
+	}
+
+	cancelled := make(map[common.Hash]bool, len(group.cancelled))
+	for hash, isCancel := range group.cancelled {
+		cancelled[hash] = isCancel
+	}
+
+	return group.original, append([]common.Hash(nil), group.txs...), cancelled
+}
+
+// ForgetReplacements drops the replacements tracked for txHash, which can be the original transaction or any of its
+// replacements. WaitForAny and WaitForReceipt forget them once a receipt is found; callers that wait in other ways
+// should call it when they are done with the transaction.
+func (s *Sender) ForgetReplacements(txHash common.Hash) {
+	s.replacementsMu.Lock()
+	defer s.replacementsMu.Unlock()
+
+	group, ok := s.replacements[txHash]
+	if !ok {
+		return
+	}
+
+	for _, hash := range group.txs {
+		delete(s.replacements, hash)
+	}
+}
+
+func bumpFee(fee *big.Int, bump uint64) *big.Int {
+	bumped := new(big.Int).Mul(fee, new(big.Int).SetUint64(100+bump))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+func maxBigInt(a *big.Int, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+
+	return b
+}
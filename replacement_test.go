@@ -0,0 +1,239 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func sendPendingTransaction(t *testing.T) (*kmstest.Client, string, *fakeBackend, *kmswallet.Sender, *types.Transaction) {
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	backend.pendingNonce = 9
+	sender := kmswallet.NewSender(provider, backend, big.NewInt(1), kmswallet.WithPollInterval(time.Millisecond))
+	to := common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+
+	tx, err := sender.SendTransaction(context.Background(), keyId, kmswallet.SendRequest{
+		To:    &to,
+		Value: big.NewInt(1000),
+		Data:  []byte{0x01},
+	})
+	assert.NoError(t, err)
+
+	return client, keyId, backend, sender, tx
+}
+
+func TestSender_SpeedUp_Should_Resign_Same_Nonce_With_Bumped_Fees(t *testing.T) {
+	// given
+	client, keyId, _, sender, tx := sendPendingTransaction(t)
+
+	// when
+	replacement, err := sender.SpeedUp(context.Background(), keyId, tx.Hash(), 25)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Nonce(), replacement.Nonce())
+	assert.Equal(t, tx.To(), replacement.To())
+	assert.Equal(t, tx.Value(), replacement.Value())
+	assert.Equal(t, tx.Data(), replacement.Data())
+	assert.Equal(t, big.NewInt(1_250_000_000), replacement.GasTipCap())
+	assert.Equal(t, big.NewInt(26_250_000_000), replacement.GasFeeCap())
+
+	from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), replacement)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), from)
+}
+
+func TestSender_SpeedUp_Should_Apply_Minimum_Replacement_Bump(t *testing.T) {
+	// given
+	_, keyId, _, sender, tx := sendPendingTransaction(t)
+
+	// when
+	replacement, err := sender.SpeedUp(context.Background(), keyId, tx.Hash(), 1)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1_100_000_000), replacement.GasTipCap())
+	assert.Equal(t, big.NewInt(23_100_000_000), replacement.GasFeeCap())
+}
+
+func TestSender_SpeedUp_Should_Fail_When_Transaction_Is_Not_Pending(t *testing.T) {
+	// given
+	_, keyId, backend, sender, tx := sendPendingTransaction(t)
+	backend.mine(tx.Hash(), types.ReceiptStatusSuccessful)
+
+	// when
+	_, err := sender.SpeedUp(context.Background(), keyId, tx.Hash(), 10)
+
+	// then
+	assert.ErrorIs(t, err, kmswallet.ErrTransactionNotPending)
+}
+
+func TestSender_Cancel_Should_Send_Zero_Value_Self_Transfer_With_Same_Nonce(t *testing.T) {
+	// given
+	client, keyId, _, sender, tx := sendPendingTransaction(t)
+
+	// when
+	cancellation, err := sender.Cancel(context.Background(), keyId, tx.Hash())
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Nonce(), cancellation.Nonce())
+	assert.Equal(t, client.Address(keyId), *cancellation.To())
+	assert.Equal(t, 0, cancellation.Value().Sign())
+	assert.Empty(t, cancellation.Data())
+	assert.Equal(t, uint64(21000), cancellation.Gas())
+}
+
+func TestSender_WaitForAny_Should_Report_Mined_Replacement(t *testing.T) {
+	// given
+	_, keyId, backend, sender, tx := sendPendingTransaction(t)
+	speedUp, err := sender.SpeedUp(context.Background(), keyId, tx.Hash(), 10)
+	assert.NoError(t, err)
+	cancellation, err := sender.Cancel(context.Background(), keyId, speedUp.Hash())
+	assert.NoError(t, err)
+	backend.mine(cancellation.Hash(), types.ReceiptStatusSuccessful)
+
+	// when
+	result, err := sender.WaitForAny(context.Background(), tx.Hash(), 1)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, cancellation.Hash(), result.Receipt.TxHash)
+	assert.True(t, result.Replaced)
+	assert.True(t, result.Cancelled)
+}
+
+func TestSender_WaitForAny_Should_Report_Original_When_It_Is_Mined(t *testing.T) {
+	// given
+	_, keyId, backend, sender, tx := sendPendingTransaction(t)
+	_, err := sender.SpeedUp(context.Background(), keyId, tx.Hash(), 10)
+	assert.NoError(t, err)
+	backend.mine(tx.Hash(), types.ReceiptStatusSuccessful)
+
+	// when
+	result, err := sender.WaitForAny(context.Background(), tx.Hash(), 1)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Hash(), result.Receipt.TxHash)
+	assert.False(t, result.Replaced)
+	assert.False(t, result.Cancelled)
+}
+
+func TestSender_WaitForAny_Should_Forget_Replacements_Once_Mined(t *testing.T) {
+	// given
+	_, keyId, backend, sender, tx := sendPendingTransaction(t)
+	speedUp, err := sender.SpeedUp(context.Background(), keyId, tx.Hash(), 10)
+	assert.NoError(t, err)
+	backend.mine(speedUp.Hash(), types.ReceiptStatusSuccessful)
+
+	result, err := sender.WaitForAny(context.Background(), tx.Hash(), 1)
+	assert.NoError(t, err)
+	assert.True(t, result.Replaced)
+
+	// when
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = sender.WaitForAny(ctx, tx.Hash(), 1)
+
+	// then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSender_SpeedUp_When_Transaction_Is_A_Blob_Transaction(t *testing.T) {
+	// given
+	_, keyId, backend, sender, _ := sendPendingTransaction(t)
+	blobTx := types.NewTx(&types.BlobTx{
+		ChainID:    uint256.NewInt(1),
+		Nonce:      10,
+		GasTipCap:  uint256.NewInt(1e9),
+		GasFeeCap:  uint256.NewInt(30e9),
+		Gas:        21000,
+		BlobFeeCap: uint256.NewInt(1e9),
+		BlobHashes: []common.Hash{{0x01}},
+	})
+	backend.sentTxs = append(backend.sentTxs, blobTx)
+
+	// when
+	_, speedUpErr := sender.SpeedUp(context.Background(), keyId, blobTx.Hash(), 10)
+	_, cancelErr := sender.Cancel(context.Background(), keyId, blobTx.Hash())
+
+	// then
+	assert.ErrorIs(t, speedUpErr, kmswallet.ErrReplacementNotSupported)
+	assert.ErrorIs(t, cancelErr, kmswallet.ErrReplacementNotSupported)
+}
+
+func TestSender_SpeedUp_Should_Keep_Access_List_Transaction_Type(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	backend.baseFee = nil
+	backend.gasPrice = big.NewInt(1e9)
+	sender := kmswallet.NewSender(provider, backend, big.NewInt(1), kmswallet.WithPollInterval(time.Millisecond))
+	to := common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+	accessList := types.AccessList{{Address: to, StorageKeys: []common.Hash{{0x01}}}}
+
+	opts, err := provider.GetWalletTransactor(context.Background(), keyId, big.NewInt(1))
+	assert.NoError(t, err)
+	tx, err := opts.Signer(opts.From, types.NewTx(&types.AccessListTx{
+		ChainID: big.NewInt(1), Nonce: 3, GasPrice: big.NewInt(10e9), Gas: 30000, To: &to, Value: big.NewInt(1), AccessList: accessList,
+	}))
+	assert.NoError(t, err)
+	backend.sentTxs = append(backend.sentTxs, tx)
+
+	// when
+	replacement, err := sender.SpeedUp(context.Background(), keyId, tx.Hash(), 20)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(types.AccessListTxType), replacement.Type())
+	assert.Equal(t, uint64(3), replacement.Nonce())
+	assert.Equal(t, big.NewInt(12e9), replacement.GasPrice())
+	assert.Equal(t, accessList, replacement.AccessList())
+}
+
+func TestSender_WaitForReceipt_Should_Forget_Replacements(t *testing.T) {
+	// given
+	_, keyId, backend, sender, tx := sendPendingTransaction(t)
+	speedUp, err := sender.SpeedUp(context.Background(), keyId, tx.Hash(), 10)
+	assert.NoError(t, err)
+	backend.mine(speedUp.Hash(), types.ReceiptStatusSuccessful)
+
+	_, err = sender.WaitForReceipt(context.Background(), speedUp.Hash(), 1)
+	assert.NoError(t, err)
+
+	// when
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = sender.WaitForAny(ctx, tx.Hash(), 1)
+
+	// then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSender_ForgetReplacements(t *testing.T) {
+	// given
+	_, keyId, backend, sender, tx := sendPendingTransaction(t)
+	speedUp, err := sender.SpeedUp(context.Background(), keyId, tx.Hash(), 10)
+	assert.NoError(t, err)
+	backend.mine(speedUp.Hash(), types.ReceiptStatusSuccessful)
+
+	// when
+	sender.ForgetReplacements(speedUp.Hash())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = sender.WaitForAny(ctx, tx.Hash(), 1)
+
+	// then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,255 @@
+// Package rpcproxy serves a JSON-RPC endpoint in front of an upstream Ethereum node that signs with KMS wallets, so
+// dapps and tools that expect an unlocked node account can use KMS keys unchanged.
+package rpcproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/internal/ethrpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+// Proxy handles eth_accounts, and eth_sendTransaction, eth_sign, personal_sign and eth_signTypedData_v4 of the
+// configured wallets. Transactions are completed with the nonce, gas and fees the upstream node suggests, signed with
+// KMS and sent with eth_sendRawTransaction. Every other request, including the signing requests of other
+// accounts, is passed through to the upstream node.
+type Proxy struct {
+	provider  kmswallet.Provider
+	upstream  *rpc.Client
+	sender    *kmswallet.Sender
+	chainId   *big.Int
+	logger    *slog.Logger
+	addresses []common.Address
+	keyIds    map[common.Address]string
+}
+
+type Option func(p *Proxy)
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Proxy) {
+		p.logger = logger
+	}
+}
+
+// NewProxy resolves the wallets of keys, which are keyIds or aliases with the "alias/" prefix, and reads the chain id
+// of upstream.
+func NewProxy(ctx context.Context, provider kmswallet.Provider, upstream *rpc.Client, keys []string, opts ...Option) (*Proxy, error) {
+	backend := ethclient.NewClient(upstream)
+	chainId, err := backend.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		provider: provider,
+		upstream: upstream,
+		sender:   kmswallet.NewSender(provider, backend, chainId, kmswallet.WithNonceManager(kmswallet.NewNonceManager(backend))),
+		chainId:  chainId,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		keyIds:   map[common.Address]string{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	for _, key := range keys {
+		keyId := key
+		if alias, ok := strings.CutPrefix(key, "alias/"); ok {
+			if keyId, err = provider.GetKeyIdByAlias(ctx, alias); err != nil {
+				return nil, err
+			}
+		}
+
+		wallet, err := provider.GetWallet(ctx, keyId)
+		if err != nil {
+			return nil, err
+		}
+
+		address := common.HexToAddress(wallet.Address)
+		if _, ok := p.keyIds[address]; ok {
+			continue
+		}
+
+		p.addresses = append(p.addresses, address)
+		p.keyIds[address] = keyId
+	}
+
+	return p, nil
+}
+
+// ServeHTTP serves JSON-RPC requests with a JSON Content-Type. It does not check the Host or Origin of requests; wrap it
+// in a Host allowlist and CORS policy, like node.NewHTTPHandlerStack of go-ethereum, when mounting it in your own server.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ethrpc.Serve(w, r, p.handle)
+}
+
+func (p *Proxy) handle(ctx context.Context, request *ethrpc.Request) (interface{}, error) {
+	switch request.Method {
+	case "eth_accounts":
+		return p.addresses, nil
+	case "eth_sendTransaction":
+		var args ethrpc.TransactionArgs
+		if err := ethrpc.ParseParams(request, &args); err != nil {
+			return nil, err
+		}
+
+		if args.From == nil {
+			return nil, ethrpc.InvalidParams("from is required")
+		}
+
+		if keyId, ok := p.keyIds[*args.From]; ok {
+			return p.sendTransaction(ctx, keyId, &args)
+		}
+	case "eth_sign":
+		var address common.Address
+		var data hexutil.Bytes
+		if err := ethrpc.ParseParams(request, &address, &data); err != nil {
+			return nil, err
+		}
+
+		if keyId, ok := p.keyIds[address]; ok {
+			signature, err := p.provider.SignMessage(ctx, keyId, data)
+			return hexutil.Bytes(signature), err
+		}
+	case "personal_sign":
+		var data hexutil.Bytes
+		var address common.Address
+		var password string
+		if err := ethrpc.ParseParams(request, &data, &address, &password); err != nil {
+			return nil, err
+		}
+
+		if keyId, ok := p.keyIds[address]; ok {
+			signature, err := p.provider.SignMessage(ctx, keyId, data)
+			return hexutil.Bytes(signature), err
+		}
+	case "eth_signTypedData_v4":
+		var address common.Address
+		var rawTypedData json.RawMessage
+		if err := ethrpc.ParseParams(request, &address, &rawTypedData); err != nil {
+			return nil, err
+		}
+
+		if keyId, ok := p.keyIds[address]; ok {
+			typedData, err := parseTypedData(rawTypedData)
+			if err != nil {
+				return nil, err
+			}
+
+			signature, err := p.provider.SignTypedData(ctx, keyId, typedData)
+			return hexutil.Bytes(signature), err
+		}
+	}
+
+	return p.forward(ctx, request)
+}
+
+func (p *Proxy) sendTransaction(ctx context.Context, keyId string, args *ethrpc.TransactionArgs) (common.Hash, error) {
+	switch {
+	case args.ChainId != nil && args.ChainId.ToInt().Cmp(p.chainId) != 0:
+		return common.Hash{}, ethrpc.InvalidParams("chainId %s does not match the chain id of the node %s", args.ChainId.ToInt(), p.chainId)
+	case args.GasPrice != nil && (args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil):
+		return common.Hash{}, ethrpc.InvalidParams("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+	}
+
+	request := kmswallet.SendRequest{
+		To:        args.To,
+		Data:      args.CallData(),
+		Value:     (*big.Int)(args.Value),
+		GasPrice:  (*big.Int)(args.GasPrice),
+		GasFeeCap: (*big.Int)(args.MaxFeePerGas),
+		GasTipCap: (*big.Int)(args.MaxPriorityFeePerGas),
+	}
+
+	if args.Gas != nil {
+		request.GasLimit = uint64(*args.Gas)
+	}
+
+	if args.Nonce != nil {
+		nonce := uint64(*args.Nonce)
+		request.Nonce = &nonce
+	}
+
+	if args.AccessList != nil {
+		request.AccessList = *args.AccessList
+	}
+
+	tx, err := p.sender.SendTransaction(ctx, keyId, request)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "can not send transaction", slog.String("from", args.From.Hex()), slog.Any("err", err))
+		return common.Hash{}, upstreamError(err)
+	}
+
+	return tx.Hash(), nil
+}
+
+// forward calls the upstream node with the request and returns its result or error unchanged.
+func (p *Proxy) forward(ctx context.Context, request *ethrpc.Request) (interface{}, error) {
+	var params []json.RawMessage
+	if len(request.Params) > 0 {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, ethrpc.InvalidParams("invalid params: %v", err)
+		}
+	}
+
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+
+	var result json.RawMessage
+	if err := p.upstream.CallContext(ctx, &result, request.Method, args...); err != nil {
+		return nil, upstreamError(err)
+	}
+
+	return result, nil
+}
+
+// upstreamError keeps the code and data of the JSON-RPC errors of the upstream node.
+func upstreamError(err error) error {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+
+	proxyErr := &ethrpc.Error{Code: rpcErr.ErrorCode(), Message: rpcErr.Error()}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) && dataErr.ErrorData() != nil {
+		proxyErr.Data, _ = json.Marshal(dataErr.ErrorData())
+	}
+
+	return proxyErr
+}
+
+// parseTypedData accepts the typed data of eth_signTypedData_v4 as an object or as a JSON encoded string, as
+// MetaMask sends it.
+func parseTypedData(raw json.RawMessage) (apitypes.TypedData, error) {
+	var typedData apitypes.TypedData
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		raw = json.RawMessage(encoded)
+	}
+
+	if err := json.Unmarshal(raw, &typedData); err != nil {
+		return typedData, ethrpc.InvalidParams("invalid typed data: %v", err)
+	}
+
+	return typedData, nil
+}
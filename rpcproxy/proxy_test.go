@@ -0,0 +1,330 @@
+package rpcproxy_test
+
+import (
+	"context"
+	"encoding/json"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/rpcproxy"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var testChainId = big.NewInt(11155111)
+
+type revertError struct{}
+
+func (revertError) Error() string          { return "execution reverted" }
+func (revertError) ErrorCode() int         { return 3 }
+func (revertError) ErrorData() interface{} { return "0x08c379a0" }
+
+// fakeNode is the eth namespace of the upstream node.
+type fakeNode struct {
+	mu        sync.Mutex
+	nonce     uint64
+	estimates []map[string]interface{}
+	rawTxs    []hexutil.Bytes
+	forwarded []string
+}
+
+func (n *fakeNode) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(testChainId)
+}
+
+func (n *fakeNode) GetTransactionCount(_ common.Address, _ string) hexutil.Uint64 {
+	return hexutil.Uint64(n.nonce)
+}
+
+func (n *fakeNode) GetBlockByNumber(_ string, _ bool) *types.Header {
+	return &types.Header{Number: big.NewInt(100), Difficulty: new(big.Int), BaseFee: big.NewInt(10e9), Extra: []byte{}}
+}
+
+func (n *fakeNode) MaxPriorityFeePerGas() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1e9))
+}
+
+func (n *fakeNode) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(20e9))
+}
+
+func (n *fakeNode) EstimateGas(args map[string]interface{}) hexutil.Uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.estimates = append(n.estimates, args)
+	return 50000
+}
+
+func (n *fakeNode) SendRawTransaction(raw hexutil.Bytes) (common.Hash, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.rawTxs = append(n.rawTxs, raw)
+
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return common.Hash{}, err
+	}
+
+	return tx.Hash(), nil
+}
+
+func (n *fakeNode) BlockNumber() hexutil.Uint64 {
+	return 100
+}
+
+func (n *fakeNode) Call(_ map[string]interface{}, _ string) (hexutil.Bytes, error) {
+	return nil, revertError{}
+}
+
+func (n *fakeNode) Sign(_ common.Address, _ hexutil.Bytes) hexutil.Bytes {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.forwarded = append(n.forwarded, "eth_sign")
+	return hexutil.Bytes{0x01}
+}
+
+func newProxy(t *testing.T) (*kmstest.Client, string, *fakeNode, *rpcproxy.Proxy) {
+	node := &fakeNode{nonce: 7}
+	upstream := rpc.NewServer()
+	if err := upstream.RegisterName("eth", node); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(upstream.Stop)
+
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	client.AddAlias("treasury", keyId)
+
+	proxy, err := rpcproxy.NewProxy(context.Background(), kmswallet.NewProvider(client, nil), rpc.DialInProc(upstream), []string{"alias/treasury"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, keyId, node, proxy
+}
+
+func newTestProxy(t *testing.T) (*kmstest.Client, string, *fakeNode, *rpc.Client) {
+	client, keyId, node, proxy := newProxy(t)
+	httpServer := httptest.NewServer(proxy)
+	t.Cleanup(httpServer.Close)
+
+	rpcClient, err := rpc.Dial(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, keyId, node, rpcClient
+}
+
+func TestSendTransaction_Should_Fill_Sign_And_Send_Raw_Transaction(t *testing.T) {
+	// given
+	client, keyId, node, rpcClient := newTestProxy(t)
+	from := client.Address(keyId)
+	to := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+
+	// when
+	var firstHash, secondHash common.Hash
+	firstErr := rpcClient.Call(&firstHash, "eth_sendTransaction", map[string]interface{}{
+		"from":  from,
+		"to":    to,
+		"value": (*hexutil.Big)(big.NewInt(1)),
+	})
+	secondErr := rpcClient.Call(&secondHash, "eth_sendTransaction", map[string]interface{}{
+		"from":     from,
+		"to":       to,
+		"gas":      hexutil.Uint64(21000),
+		"gasPrice": (*hexutil.Big)(big.NewInt(5e9)),
+	})
+
+	// then
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	assert.Len(t, node.rawTxs, 2)
+	assert.Len(t, node.estimates, 1)
+
+	first, second := new(types.Transaction), new(types.Transaction)
+	assert.NoError(t, first.UnmarshalBinary(node.rawTxs[0]))
+	assert.NoError(t, second.UnmarshalBinary(node.rawTxs[1]))
+	assert.Equal(t, firstHash, first.Hash())
+	assert.Equal(t, secondHash, second.Hash())
+
+	assert.Equal(t, uint8(types.DynamicFeeTxType), first.Type())
+	assert.Equal(t, uint64(7), first.Nonce())
+	assert.Equal(t, uint64(50000), first.Gas())
+	assert.Equal(t, big.NewInt(1e9), first.GasTipCap())
+	assert.Equal(t, big.NewInt(21e9), first.GasFeeCap())
+	assert.Equal(t, testChainId, first.ChainId())
+
+	assert.Equal(t, uint8(types.LegacyTxType), second.Type())
+	assert.Equal(t, uint64(8), second.Nonce())
+	assert.Equal(t, uint64(21000), second.Gas())
+	assert.Equal(t, big.NewInt(5e9), second.GasPrice())
+
+	for _, tx := range []*types.Transaction{first, second} {
+		sender, err := types.Sender(types.LatestSignerForChainID(testChainId), tx)
+		assert.NoError(t, err)
+		assert.Equal(t, from, sender)
+	}
+}
+
+func TestSendTransaction_Should_Continue_After_Requested_Nonce(t *testing.T) {
+	// given
+	client, keyId, node, rpcClient := newTestProxy(t)
+	from := client.Address(keyId)
+	to := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+
+	// when
+	var explicitHash, implicitHash common.Hash
+	explicitErr := rpcClient.Call(&explicitHash, "eth_sendTransaction", map[string]interface{}{
+		"from":  from,
+		"to":    to,
+		"nonce": hexutil.Uint64(7),
+	})
+	implicitErr := rpcClient.Call(&implicitHash, "eth_sendTransaction", map[string]interface{}{
+		"from": from,
+		"to":   to,
+	})
+
+	// then
+	assert.NoError(t, explicitErr)
+	assert.NoError(t, implicitErr)
+	assert.Len(t, node.rawTxs, 2)
+
+	explicit, implicit := new(types.Transaction), new(types.Transaction)
+	assert.NoError(t, explicit.UnmarshalBinary(node.rawTxs[0]))
+	assert.NoError(t, implicit.UnmarshalBinary(node.rawTxs[1]))
+	assert.Equal(t, uint64(7), explicit.Nonce())
+	assert.Equal(t, uint64(8), implicit.Nonce())
+}
+
+func TestSendTransaction_When_Chain_Id_Does_Not_Match(t *testing.T) {
+	// given
+	client, keyId, node, rpcClient := newTestProxy(t)
+
+	// when
+	var hash common.Hash
+	err := rpcClient.Call(&hash, "eth_sendTransaction", map[string]interface{}{
+		"from":    client.Address(keyId),
+		"chainId": (*hexutil.Big)(big.NewInt(1)),
+	})
+
+	// then
+	assert.ErrorContains(t, err, "chainId 1 does not match the chain id of the node 11155111")
+	assert.Empty(t, node.rawTxs)
+}
+
+func TestSigningMethods(t *testing.T) {
+	// given
+	client, keyId, _, rpcClient := newTestProxy(t)
+	address := client.Address(keyId)
+	message := []byte("hello proxy")
+	typedData := kmswallet.PermitTypedData(address, kmswallet.PermitToken{
+		Address: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+		Name:    "USD Coin",
+		Version: "2",
+	}, common.HexToAddress("0x01"), big.NewInt(1), big.NewInt(0), big.NewInt(1_700_000_000), testChainId)
+	encodedTypedData, _ := json.Marshal(typedData)
+
+	// when
+	var addresses []common.Address
+	accountsErr := rpcClient.Call(&addresses, "eth_accounts")
+
+	var ethSignature, personalSignature, typedSignature, encodedTypedSignature hexutil.Bytes
+	ethSignErr := rpcClient.Call(&ethSignature, "eth_sign", address, hexutil.Bytes(message))
+	personalSignErr := rpcClient.Call(&personalSignature, "personal_sign", hexutil.Bytes(message), address)
+	typedErr := rpcClient.Call(&typedSignature, "eth_signTypedData_v4", address, typedData)
+	encodedTypedErr := rpcClient.Call(&encodedTypedSignature, "eth_signTypedData_v4", address, string(encodedTypedData))
+
+	// then
+	assert.NoError(t, accountsErr)
+	assert.Equal(t, []common.Address{address}, addresses)
+
+	assert.NoError(t, ethSignErr)
+	assert.NoError(t, personalSignErr)
+	for _, signature := range []hexutil.Bytes{ethSignature, personalSignature} {
+		signer, err := kmswallet.RecoverAddress(accounts.TextHash(message), signature)
+		assert.NoError(t, err)
+		assert.Equal(t, address, signer)
+	}
+
+	assert.NoError(t, typedErr)
+	assert.NoError(t, encodedTypedErr)
+	for _, signature := range []hexutil.Bytes{typedSignature, encodedTypedSignature} {
+		valid, err := kmswallet.VerifyTypedDataSignature(address, typedData, signature)
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	// given
+	_, _, node, rpcClient := newTestProxy(t)
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	// when
+	var blockNumber hexutil.Uint64
+	blockNumberErr := rpcClient.Call(&blockNumber, "eth_blockNumber")
+
+	var signature hexutil.Bytes
+	signErr := rpcClient.Call(&signature, "eth_sign", other, hexutil.Bytes("hello"))
+
+	var result hexutil.Bytes
+	callErr := rpcClient.Call(&result, "eth_call", map[string]interface{}{"to": other}, "latest")
+
+	var ignored interface{}
+	unknownErr := rpcClient.Call(&ignored, "debug_traceTransaction", common.Hash{})
+
+	// then
+	assert.NoError(t, blockNumberErr)
+	assert.Equal(t, hexutil.Uint64(100), blockNumber)
+
+	assert.NoError(t, signErr)
+	assert.Equal(t, hexutil.Bytes{0x01}, signature)
+	assert.Equal(t, []string{"eth_sign"}, node.forwarded)
+
+	var dataErr rpc.DataError
+	assert.ErrorAs(t, callErr, &dataErr)
+	assert.Equal(t, "execution reverted", dataErr.Error())
+	assert.Equal(t, "0x08c379a0", dataErr.ErrorData())
+	assert.Equal(t, 3, callErr.(rpc.Error).ErrorCode())
+
+	assert.ErrorContains(t, unknownErr, "the method debug_traceTransaction does not exist/is not available")
+}
+
+func TestServeHTTP_Should_Require_JSON_Content_Type(t *testing.T) {
+	// given
+	_, _, _, proxy := newProxy(t)
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_accounts"}`
+
+	newRequest := func(contentType string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		return request
+	}
+
+	// when
+	plain := httptest.NewRecorder()
+	proxy.ServeHTTP(plain, newRequest("text/plain"))
+
+	form := httptest.NewRecorder()
+	proxy.ServeHTTP(form, newRequest("application/x-www-form-urlencoded"))
+
+	accepted := httptest.NewRecorder()
+	proxy.ServeHTTP(accepted, newRequest("application/json; charset=utf-8"))
+
+	// then
+	assert.Equal(t, http.StatusUnsupportedMediaType, plain.Code)
+	assert.Equal(t, http.StatusUnsupportedMediaType, form.Code)
+	assert.Equal(t, http.StatusOK, accepted.Code)
+	assert.Contains(t, accepted.Body.String(), `"result":[`)
+}
@@ -0,0 +1,186 @@
+package kmswallet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"math/big"
+	"sort"
+)
+
+const (
+	SafeOperationCall         uint8 = 0
+	SafeOperationDelegateCall uint8 = 1
+)
+
+// WalletKey references a KMS wallet of a provider. Keys of different providers can live in different AWS accounts
+// or regions.
+type WalletKey struct {
+	Provider Provider
+	KeyId    string
+}
+
+// SafeTransaction holds the execTransaction arguments of a Safe transaction, except the signatures.
+type SafeTransaction struct {
+	To             common.Address
+	Value          *big.Int
+	Data           []byte
+	Operation      uint8
+	SafeTxGas      *big.Int
+	BaseGas        *big.Int
+	GasPrice       *big.Int
+	GasToken       common.Address
+	RefundReceiver common.Address
+	Nonce          *big.Int
+}
+
+type SafeSignature struct {
+	Owner     common.Address
+	Signature []byte
+}
+
+// SafeSigner signs transactions of a Safe (version 1.3.0 or later) with its KMS-held owners.
+type SafeSigner struct {
+	safe    common.Address
+	chainId *big.Int
+	owners  []WalletKey
+}
+
+func NewSafeSigner(safe common.Address, chainId *big.Int, owners ...WalletKey) *SafeSigner {
+	return &SafeSigner{
+		safe:    safe,
+		chainId: chainId,
+		owners:  owners,
+	}
+}
+
+// SafeTransactionTypedData returns the SafeTx EIP-712 typed data of tx for the Safe at safe.
+func SafeTransactionTypedData(safe common.Address, chainId *big.Int, tx SafeTransaction) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"SafeTx": {
+				{Name: "to", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "data", Type: "bytes"},
+				{Name: "operation", Type: "uint8"},
+				{Name: "safeTxGas", Type: "uint256"},
+				{Name: "baseGas", Type: "uint256"},
+				{Name: "gasPrice", Type: "uint256"},
+				{Name: "gasToken", Type: "address"},
+				{Name: "refundReceiver", Type: "address"},
+				{Name: "nonce", Type: "uint256"},
+			},
+		},
+		PrimaryType: "SafeTx",
+		Domain: apitypes.TypedDataDomain{
+			ChainId:           (*math.HexOrDecimal256)(chainId),
+			VerifyingContract: safe.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"to":             tx.To.Hex(),
+			"value":          bigIntString(tx.Value),
+			"data":           hexutil.Encode(tx.Data),
+			"operation":      fmt.Sprint(tx.Operation),
+			"safeTxGas":      bigIntString(tx.SafeTxGas),
+			"baseGas":        bigIntString(tx.BaseGas),
+			"gasPrice":       bigIntString(tx.GasPrice),
+			"gasToken":       tx.GasToken.Hex(),
+			"refundReceiver": tx.RefundReceiver.Hex(),
+			"nonce":          bigIntString(tx.Nonce),
+		},
+	}
+}
+
+// TransactionHash returns the Safe transaction hash of tx, the value returned by the Safe's getTransactionHash.
+func (s *SafeSigner) TransactionHash(tx SafeTransaction) (common.Hash, error) {
+	hash, _, err := apitypes.TypedDataAndHash(SafeTransactionTypedData(s.safe, s.chainId, tx))
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return common.BytesToHash(hash), nil
+}
+
+// Signatures signs tx with every owner and returns the signatures sorted by owner address.
+func (s *SafeSigner) Signatures(ctx context.Context, tx SafeTransaction) ([]SafeSignature, error) {
+	hash, err := s.TransactionHash(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.signHash(ctx, hash)
+}
+
+func (s *SafeSigner) signHash(ctx context.Context, hash common.Hash) ([]SafeSignature, error) {
+	signatures := make([]SafeSignature, 0, len(s.owners))
+	for _, owner := range s.owners {
+		signature, err := owner.Provider.SignHash(ctx, owner.KeyId, hash.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("can not sign safe hash with keyId: %s, err: %w", owner.KeyId, err)
+		}
+
+		address, err := RecoverAddress(hash.Bytes(), signature)
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = append(signatures, SafeSignature{Owner: address, Signature: signature})
+	}
+
+	sortSafeSignatures(signatures)
+	return signatures, nil
+}
+
+// Sign signs tx with every owner and returns the signatures argument of execTransaction.
+func (s *SafeSigner) Sign(ctx context.Context, tx SafeTransaction) ([]byte, error) {
+	signatures, err := s.Signatures(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return JoinSafeSignatures(signatures...)
+}
+
+// JoinSafeSignatures concatenates ECDSA owner signatures, e.g. collected from several signers, sorted by owner
+// address as the Safe requires.
+func JoinSafeSignatures(signatures ...SafeSignature) ([]byte, error) {
+	sorted := append([]SafeSignature{}, signatures...)
+	sortSafeSignatures(sorted)
+
+	joined := make([]byte, 0, len(sorted)*65)
+	for i, signature := range sorted {
+		if i > 0 && sorted[i-1].Owner == signature.Owner {
+			return nil, fmt.Errorf("duplicate safe owner signature: %s", signature.Owner)
+		}
+
+		if len(signature.Signature) != 65 || signature.Signature[64] < 27 {
+			return nil, fmt.Errorf("%w: safe owner signatures are required to be 65 bytes with V as 27 or 28", ErrInvalidSignature)
+		}
+
+		joined = append(joined, signature.Signature...)
+	}
+
+	return joined, nil
+}
+
+func sortSafeSignatures(signatures []SafeSignature) {
+	sort.Slice(signatures, func(i, j int) bool {
+		return bytes.Compare(signatures[i].Owner.Bytes(), signatures[j].Owner.Bytes()) < 0
+	})
+}
+
+func bigIntString(value *big.Int) string {
+	if value == nil {
+		return "0"
+	}
+
+	return value.String()
+}
@@ -0,0 +1,127 @@
+package kmswallet_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+var testSafeAddress = common.HexToAddress("0x4F2083f5fBede34C2714aFfb3105539775f7FE64")
+
+func newTestSafeTransaction() kmswallet.SafeTransaction {
+	return kmswallet.SafeTransaction{
+		To:        common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582"),
+		Value:     big.NewInt(1_000_000_000_000_000),
+		Data:      hexutil.MustDecode("0xa9059cbb"),
+		Operation: kmswallet.SafeOperationCall,
+		Nonce:     big.NewInt(12),
+	}
+}
+
+func TestSafeSigner_TransactionHash(t *testing.T) {
+	// given
+	chainId := big.NewInt(100)
+	tx := newTestSafeTransaction()
+	safeSigner := kmswallet.NewSafeSigner(testSafeAddress, chainId)
+
+	domainSeparator := crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(uint256 chainId,address verifyingContract)")),
+		word(chainId),
+		common.LeftPadBytes(testSafeAddress.Bytes(), 32),
+	)
+	structHash := crypto.Keccak256(
+		crypto.Keccak256([]byte("SafeTx(address to,uint256 value,bytes data,uint8 operation,uint256 safeTxGas,uint256 baseGas,uint256 gasPrice,address gasToken,address refundReceiver,uint256 nonce)")),
+		common.LeftPadBytes(tx.To.Bytes(), 32),
+		word(tx.Value),
+		crypto.Keccak256(tx.Data),
+		word(big.NewInt(0)),
+		word(big.NewInt(0)),
+		word(big.NewInt(0)),
+		word(big.NewInt(0)),
+		common.LeftPadBytes(common.Address{}.Bytes(), 32),
+		common.LeftPadBytes(common.Address{}.Bytes(), 32),
+		word(tx.Nonce),
+	)
+
+	// when
+	hash, err := safeSigner.TransactionHash(tx)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, common.BytesToHash(eip712Digest(domainSeparator, structHash)), hash)
+}
+
+func TestSafeSigner_Sign_Should_Sort_Signatures_By_Owner(t *testing.T) {
+	// given
+	chainId := big.NewInt(1)
+	var owners []kmswallet.WalletKey
+	var addresses []common.Address
+	for i := 0; i < 3; i++ {
+		client, keyId := newTestKMSClient(t)
+		owners = append(owners, kmswallet.WalletKey{Provider: kmswallet.NewProvider(client, nil), KeyId: keyId})
+		addresses = append(addresses, client.Address(keyId))
+	}
+
+	safeSigner := kmswallet.NewSafeSigner(testSafeAddress, chainId, owners...)
+	tx := newTestSafeTransaction()
+	hash, _ := safeSigner.TransactionHash(tx)
+
+	// when
+	signatures, err := safeSigner.Sign(context.Background(), tx)
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, signatures, 3*65)
+	var previous common.Address
+	for i := 0; i < 3; i++ {
+		signature := signatures[i*65 : (i+1)*65]
+		assert.Contains(t, []byte{27, 28}, signature[64])
+
+		owner, err := kmswallet.RecoverAddress(hash.Bytes(), signature)
+		assert.NoError(t, err)
+		assert.Contains(t, addresses, owner)
+		assert.Equal(t, -1, bytes.Compare(previous.Bytes(), owner.Bytes()))
+		previous = owner
+	}
+}
+
+func TestSafeSigner_Sign_When_Owner_Fails(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	failingClient, failingKeyId := newTestKMSClient(t)
+	failingClient.SetSignHook(func(context.Context, []byte) error {
+		return errors.New("access denied")
+	})
+
+	safeSigner := kmswallet.NewSafeSigner(testSafeAddress, big.NewInt(1),
+		kmswallet.WalletKey{Provider: kmswallet.NewProvider(client, nil), KeyId: keyId},
+		kmswallet.WalletKey{Provider: kmswallet.NewProvider(failingClient, nil), KeyId: failingKeyId},
+	)
+
+	// when
+	signatures, err := safeSigner.Sign(context.Background(), newTestSafeTransaction())
+
+	// then
+	assert.ErrorContains(t, err, failingKeyId)
+	assert.Nil(t, signatures)
+}
+
+func TestJoinSafeSignatures_Should_Reject_Duplicate_Owners(t *testing.T) {
+	// given
+	signature := kmswallet.SafeSignature{Owner: common.HexToAddress("0x01"), Signature: make([]byte, 65)}
+	signature.Signature[64] = 27
+
+	// when
+	joined, err := kmswallet.JoinSafeSignatures(signature, signature)
+
+	// then
+	assert.ErrorContains(t, err, "duplicate safe owner signature")
+	assert.Nil(t, joined)
+}
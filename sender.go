@@ -0,0 +1,310 @@
+package kmswallet
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ether_types "github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTransactionFailed = errors.New("transaction execution failed")
+	defaultPollInterval  = 2 * time.Second
+)
+
+type SenderBackend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	TransactionByHash(ctx context.Context, txHash common.Hash) (tx *ether_types.Transaction, isPending bool, err error)
+}
+
+type SendRequest struct {
+	To    *common.Address
+	Value *big.Int
+	Data  []byte
+	// GasLimit is estimated when it is zero.
+	GasLimit uint64
+	// Confirmations is the number of blocks, including the inclusion block, to wait for. Zero is treated as one.
+	Confirmations uint64
+	// Nonce is reserved from the nonce manager or the backend when it is nil. Once a requested nonce is sent, the nonce
+	// manager continues after it.
+	Nonce *uint64
+	// GasPrice makes the transaction a legacy (or access list) transaction. When neither GasPrice nor the EIP-1559
+	// fees are set, the fees are suggested by the backend; a missing GasFeeCap is GasTipCap plus twice the base fee.
+	GasPrice   *big.Int
+	GasFeeCap  *big.Int
+	GasTipCap  *big.Int
+	AccessList ether_types.AccessList
+}
+
+// Sender builds, signs and broadcasts transactions from KMS wallets and tracks them until they are confirmed.
+type Sender struct {
+	provider     Provider
+	backend      SenderBackend
+	chainId      *big.Int
+	nonces       *NonceManager
+	pollInterval time.Duration
+
+	replacementsMu sync.Mutex
+	replacements   map[common.Hash]*replacementGroup
+}
+
+type SenderOption func(s *Sender)
+
+// WithNonceManager makes the sender reserve nonces from nonces instead of asking the backend for every transaction.
+func WithNonceManager(nonces *NonceManager) SenderOption {
+	return func(s *Sender) {
+		s.nonces = nonces
+	}
+}
+
+// WithPollInterval sets how often the sender polls the backend for receipts and new blocks.
+func WithPollInterval(interval time.Duration) SenderOption {
+	return func(s *Sender) {
+		s.pollInterval = interval
+	}
+}
+
+func NewSender(provider Provider, backend SenderBackend, chainId *big.Int, opts ...SenderOption) *Sender {
+	s := &Sender{
+		provider:     provider,
+		backend:      backend,
+		chainId:      chainId,
+		pollInterval: defaultPollInterval,
+		replacements: map[common.Hash]*replacementGroup{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Send signs and broadcasts the transaction described by request and waits for its confirmations.
+// The receipt is returned together with ErrTransactionFailed if the transaction was mined but reverted.
+func (s *Sender) Send(ctx context.Context, keyId string, request SendRequest) (*ether_types.Receipt, error) {
+	tx, err := s.SendTransaction(ctx, keyId, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.WaitForReceipt(ctx, tx.Hash(), request.Confirmations)
+}
+
+// SendTransaction signs and broadcasts the transaction described by request without waiting for it to be mined.
+func (s *Sender) SendTransaction(ctx context.Context, keyId string, request SendRequest) (*ether_types.Transaction, error) {
+	opts, err := s.provider.GetWalletTransactor(ctx, keyId, s.chainId)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Context = ctx
+	nonce, err := s.nonce(ctx, opts.From, request)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.buildTransaction(ctx, opts.From, nonce, request)
+	if err != nil {
+		s.releaseNonce(opts.From, nonce, request, err)
+		return nil, err
+	}
+
+	signedTx, err := opts.Signer(opts.From, tx)
+	if err != nil {
+		s.releaseNonce(opts.From, nonce, request, err)
+		return nil, err
+	}
+
+	err = s.backend.SendTransaction(ctx, signedTx)
+	s.releaseNonce(opts.From, nonce, request, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return signedTx, nil
+}
+
+// WaitForReceipt polls the backend until the transaction is mined and has the given number of confirmations. The
+// replacements tracked for txHash are forgotten once its receipt is found.
+func (s *Sender) WaitForReceipt(ctx context.Context, txHash common.Hash, confirmations uint64) (*ether_types.Receipt, error) {
+	if confirmations == 0 {
+		confirmations = 1
+	}
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := s.confirmedReceipt(ctx, txHash, confirmations)
+		if err != nil {
+			return nil, err
+		}
+
+		if receipt != nil {
+			s.ForgetReplacements(txHash)
+			if receipt.Status == ether_types.ReceiptStatusFailed {
+				return receipt, ErrTransactionFailed
+			}
+
+			return receipt, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sender) confirmedReceipt(ctx context.Context, txHash common.Hash, confirmations uint64) (*ether_types.Receipt, error) {
+	receipt, err := s.backend.TransactionReceipt(ctx, txHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if confirmations == 1 {
+		return receipt, nil
+	}
+
+	head, err := s.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// A head behind the receipt block, after a reorg or from a lagging node, does not confirm anything yet.
+	if head.Number.Cmp(receipt.BlockNumber) < 0 || new(big.Int).Sub(head.Number, receipt.BlockNumber).Uint64()+1 < confirmations {
+		return nil, nil
+	}
+
+	return receipt, nil
+}
+
+func (s *Sender) buildTransaction(ctx context.Context, from common.Address, nonce uint64, request SendRequest) (*ether_types.Transaction, error) {
+	value := request.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	gasPrice, gasFeeCap, gasTipCap := request.GasPrice, request.GasFeeCap, request.GasTipCap
+	if gasPrice == nil && (gasFeeCap == nil || gasTipCap == nil) {
+		head, err := s.backend.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if head.BaseFee == nil && gasFeeCap == nil && gasTipCap == nil {
+			if gasPrice, err = s.backend.SuggestGasPrice(ctx); err != nil {
+				return nil, err
+			}
+		} else {
+			if gasTipCap == nil {
+				if gasTipCap, err = s.backend.SuggestGasTipCap(ctx); err != nil {
+					return nil, err
+				}
+
+				if gasFeeCap != nil && gasTipCap.Cmp(gasFeeCap) > 0 {
+					gasTipCap = gasFeeCap
+				}
+			}
+
+			if gasFeeCap == nil {
+				baseFee := head.BaseFee
+				if baseFee == nil {
+					baseFee = new(big.Int)
+				}
+
+				gasFeeCap = new(big.Int).Add(gasTipCap, new(big.Int).Mul(baseFee, big.NewInt(2)))
+			}
+		}
+	}
+
+	if gasPrice != nil {
+		gasLimit, err := s.gasLimit(ctx, ethereum.CallMsg{From: from, To: request.To, GasPrice: gasPrice, Value: value, Data: request.Data, AccessList: request.AccessList}, request)
+		if err != nil {
+			return nil, err
+		}
+
+		if request.AccessList != nil {
+			return ether_types.NewTx(&ether_types.AccessListTx{
+				ChainID:    s.chainId,
+				Nonce:      nonce,
+				GasPrice:   gasPrice,
+				Gas:        gasLimit,
+				To:         request.To,
+				Value:      value,
+				Data:       request.Data,
+				AccessList: request.AccessList,
+			}), nil
+		}
+
+		return ether_types.NewTx(&ether_types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: gasPrice,
+			Gas:      gasLimit,
+			To:       request.To,
+			Value:    value,
+			Data:     request.Data,
+		}), nil
+	}
+
+	gasLimit, err := s.gasLimit(ctx, ethereum.CallMsg{From: from, To: request.To, GasFeeCap: gasFeeCap, GasTipCap: gasTipCap, Value: value, Data: request.Data, AccessList: request.AccessList}, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return ether_types.NewTx(&ether_types.DynamicFeeTx{
+		ChainID:    s.chainId,
+		Nonce:      nonce,
+		GasTipCap:  gasTipCap,
+		GasFeeCap:  gasFeeCap,
+		Gas:        gasLimit,
+		To:         request.To,
+		Value:      value,
+		Data:       request.Data,
+		AccessList: request.AccessList,
+	}), nil
+}
+
+func (s *Sender) gasLimit(ctx context.Context, call ethereum.CallMsg, request SendRequest) (uint64, error) {
+	if request.GasLimit > 0 {
+		return request.GasLimit, nil
+	}
+
+	return s.backend.EstimateGas(ctx, call)
+}
+
+func (s *Sender) nonce(ctx context.Context, from common.Address, request SendRequest) (uint64, error) {
+	if request.Nonce != nil {
+		return *request.Nonce, nil
+	}
+
+	if s.nonces != nil {
+		return s.nonces.Next(ctx, from)
+	}
+
+	return s.backend.PendingNonceAt(ctx, from)
+}
+
+// releaseNonce reports the send result to the nonce manager. Requested nonces are not reserved, so a sent one only
+// moves the manager past it.
+func (s *Sender) releaseNonce(from common.Address, nonce uint64, request SendRequest, err error) {
+	switch {
+	case s.nonces == nil:
+	case request.Nonce == nil:
+		s.nonces.HandleSendError(from, nonce, err)
+	case err == nil:
+		s.nonces.MarkUsed(from, nonce)
+	}
+}
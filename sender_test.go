@@ -0,0 +1,186 @@
+package kmswallet_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestSender_Send_Should_Sign_Broadcast_And_Wait_For_Confirmations(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	backend.pendingNonce = 4
+	sender := kmswallet.NewSender(provider, backend, big.NewInt(1), kmswallet.WithPollInterval(time.Millisecond))
+	to := common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+
+	go func() {
+		for backend.lastSentTx() == nil {
+			time.Sleep(time.Millisecond)
+		}
+
+		backend.mine(backend.lastSentTx().Hash(), types.ReceiptStatusSuccessful)
+		backend.advance(2)
+	}()
+
+	// when
+	receipt, err := sender.Send(context.Background(), keyId, kmswallet.SendRequest{
+		To:            &to,
+		Value:         big.NewInt(1000),
+		Confirmations: 3,
+	})
+
+	// then
+	assert.NoError(t, err)
+	tx := backend.lastSentTx()
+	assert.Equal(t, tx.Hash(), receipt.TxHash)
+	assert.Equal(t, uint8(types.DynamicFeeTxType), tx.Type())
+	assert.Equal(t, uint64(4), tx.Nonce())
+	assert.Equal(t, uint64(21000), tx.Gas())
+	assert.Equal(t, big.NewInt(1_000_000_000), tx.GasTipCap())
+	assert.Equal(t, big.NewInt(21_000_000_000), tx.GasFeeCap())
+
+	from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), tx)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), from)
+}
+
+func TestSender_Send_Should_Use_Legacy_Transaction_When_Chain_Has_No_Base_Fee(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	backend.baseFee = nil
+	sender := kmswallet.NewSender(provider, backend, big.NewInt(1))
+	to := common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+
+	// when
+	tx, err := sender.SendTransaction(context.Background(), keyId, kmswallet.SendRequest{To: &to, GasLimit: 50000})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(types.LegacyTxType), tx.Type())
+	assert.Equal(t, uint64(50000), tx.Gas())
+	assert.Equal(t, big.NewInt(20_000_000_000), tx.GasPrice())
+}
+
+func TestSender_SendTransaction_Should_Use_Requested_Nonce_And_Fees(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	sender := kmswallet.NewSender(provider, backend, big.NewInt(1))
+	to := common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+	nonce := uint64(42)
+	accessList := types.AccessList{{Address: to, StorageKeys: []common.Hash{{0x01}}}}
+
+	// when
+	dynamicTx, dynamicErr := sender.SendTransaction(context.Background(), keyId, kmswallet.SendRequest{
+		To:        &to,
+		Nonce:     &nonce,
+		GasFeeCap: big.NewInt(50_000_000_000),
+	})
+	accessListTx, accessListErr := sender.SendTransaction(context.Background(), keyId, kmswallet.SendRequest{
+		To:         &to,
+		GasPrice:   big.NewInt(7_000_000_000),
+		AccessList: accessList,
+	})
+
+	// then
+	assert.NoError(t, dynamicErr)
+	assert.Equal(t, uint8(types.DynamicFeeTxType), dynamicTx.Type())
+	assert.Equal(t, uint64(42), dynamicTx.Nonce())
+	assert.Equal(t, big.NewInt(50_000_000_000), dynamicTx.GasFeeCap())
+	assert.Equal(t, big.NewInt(1_000_000_000), dynamicTx.GasTipCap())
+
+	assert.NoError(t, accessListErr)
+	assert.Equal(t, uint8(types.AccessListTxType), accessListTx.Type())
+	assert.Equal(t, uint64(0), accessListTx.Nonce())
+	assert.Equal(t, big.NewInt(7_000_000_000), accessListTx.GasPrice())
+	assert.Equal(t, accessList, accessListTx.AccessList())
+	assert.Equal(t, 1, backend.pendingNonceCalls)
+}
+
+func TestSender_Send_Should_Return_Receipt_With_Error_When_Transaction_Reverted(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	sender := kmswallet.NewSender(provider, backend, big.NewInt(1), kmswallet.WithPollInterval(time.Millisecond))
+	to := common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+
+	tx, err := sender.SendTransaction(context.Background(), keyId, kmswallet.SendRequest{To: &to})
+	assert.NoError(t, err)
+	backend.mine(tx.Hash(), types.ReceiptStatusFailed)
+
+	// when
+	receipt, err := sender.WaitForReceipt(context.Background(), tx.Hash(), 1)
+
+	// then
+	assert.ErrorIs(t, err, kmswallet.ErrTransactionFailed)
+	assert.Equal(t, tx.Hash(), receipt.TxHash)
+}
+
+func TestSender_Send_Should_Release_Nonce_When_Broadcast_Failed(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	backend := newFakeBackend()
+	nonces := kmswallet.NewNonceManager(backend)
+	sender := kmswallet.NewSender(provider, backend, big.NewInt(1), kmswallet.WithNonceManager(nonces))
+	to := common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+	backend.sendErr = errors.New("insufficient funds for gas * price + value")
+
+	// when
+	_, err := sender.SendTransaction(context.Background(), keyId, kmswallet.SendRequest{To: &to})
+	backend.sendErr = nil
+	tx, retryErr := sender.SendTransaction(context.Background(), keyId, kmswallet.SendRequest{To: &to})
+
+	// then
+	assert.Error(t, err)
+	assert.NoError(t, retryErr)
+	assert.Equal(t, uint64(0), tx.Nonce())
+}
+
+func TestSender_WaitForReceipt_Should_Stop_When_Context_Is_Done(t *testing.T) {
+	// given
+	provider := kmswallet.NewProvider(kmstest.NewClient(), nil)
+	sender := kmswallet.NewSender(provider, newFakeBackend(), big.NewInt(1), kmswallet.WithPollInterval(time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// when
+	_, err := sender.WaitForReceipt(ctx, common.HexToHash("0x01"), 1)
+
+	// then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSender_WaitForReceipt_Should_Wait_When_Head_Is_Behind_Receipt_Block(t *testing.T) {
+	// given
+	backend := newFakeBackend()
+	sender := kmswallet.NewSender(kmswallet.NewProvider(kmstest.NewClient(), nil), backend, big.NewInt(1), kmswallet.WithPollInterval(time.Millisecond))
+	txHash := common.HexToHash("0x01")
+	backend.receipts[txHash] = &types.Receipt{TxHash: txHash, Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(105)}
+
+	// when
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, behindErr := sender.WaitForReceipt(ctx, txHash, 3)
+
+	backend.advance(7)
+	receipt, err := sender.WaitForReceipt(context.Background(), txHash, 3)
+
+	// then
+	assert.ErrorIs(t, behindErr, context.DeadlineExceeded)
+	assert.NoError(t, err)
+	assert.Equal(t, txHash, receipt.TxHash)
+}
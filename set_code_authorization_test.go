@@ -0,0 +1,91 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func TestSetCodeAuthorization_SigHash(t *testing.T) {
+	// given
+	authorization := kmswallet.SetCodeAuthorization{
+		ChainId: big.NewInt(1),
+		Address: common.HexToAddress("0x000000000000000000000000000000000000aaaa"),
+		Nonce:   1,
+	}
+
+	// rlp([0x01, 0x94 || address, 0x01]) = 0xd7 0x01 0x94 <20 bytes> 0x01
+	payload := append([]byte{0x05, 0xd7, 0x01, 0x94}, authorization.Address.Bytes()...)
+	payload = append(payload, 0x01)
+
+	// when
+	hash := authorization.SigHash()
+
+	// then
+	assert.Equal(t, crypto.Keccak256Hash(payload), hash)
+}
+
+func TestSignSetCodeAuthorization(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	delegate := common.HexToAddress("0x63c0c19a282a1B52b07dD5a65b58948A07DAE32B")
+
+	// when
+	authorization, err := provider.SignSetCodeAuthorization(context.Background(), keyId, big.NewInt(1), delegate, 7)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), authorization.ChainId)
+	assert.Equal(t, delegate, authorization.Address)
+	assert.Equal(t, uint64(7), authorization.Nonce)
+	assert.LessOrEqual(t, authorization.V, uint8(1))
+
+	authority, err := authorization.Authority()
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), authority)
+}
+
+func TestSignSetCodeAuthorization_Should_Allow_Any_Chain(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+
+	// when
+	authorization, err := provider.SignSetCodeAuthorization(context.Background(), keyId, big.NewInt(0), common.Address{0x01}, 0)
+	_, nilChainErr := provider.SignSetCodeAuthorization(context.Background(), keyId, nil, common.Address{0x01}, 0)
+
+	// then
+	assert.NoError(t, err)
+	assert.ErrorIs(t, nilChainErr, bind.ErrNoChainID)
+	assert.Equal(t, 0, authorization.ChainId.Sign())
+	authority, err := authorization.Authority()
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), authority)
+}
+
+func TestSetCodeAuthorization_Authority_When_Signature_Is_Malformed(t *testing.T) {
+	// given
+	wide := new(big.Int).Lsh(big.NewInt(1), 256)
+	authorizations := []kmswallet.SetCodeAuthorization{
+		{ChainId: big.NewInt(1), S: big.NewInt(1)},
+		{ChainId: big.NewInt(1), R: big.NewInt(1)},
+		{ChainId: big.NewInt(1), R: wide, S: big.NewInt(1)},
+		{ChainId: big.NewInt(1), R: big.NewInt(1), S: wide},
+		{ChainId: big.NewInt(1), R: big.NewInt(-1), S: big.NewInt(1)},
+		{ChainId: big.NewInt(1), R: big.NewInt(1), S: big.NewInt(1), V: 2},
+	}
+
+	for i, authorization := range authorizations {
+		// when
+		_, err := authorization.Authority()
+
+		// then
+		assert.ErrorContains(t, err, "invalid set code authorization signature", i)
+	}
+}
@@ -0,0 +1,167 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func newTestBlobTransaction(t *testing.T, chainId *big.Int) *types.Transaction {
+	var blob kzg4844.Blob
+	blob[0] = 0x01
+	commitment, err := kzg4844.BlobToCommitment(&blob)
+	assert.NoError(t, err)
+	proof, err := kzg4844.ComputeBlobProof(&blob, commitment)
+	assert.NoError(t, err)
+
+	sidecar := &types.BlobTxSidecar{
+		Blobs:       []kzg4844.Blob{blob},
+		Commitments: []kzg4844.Commitment{commitment},
+		Proofs:      []kzg4844.Proof{proof},
+	}
+
+	return types.NewTx(&types.BlobTx{
+		ChainID:    uint256.MustFromBig(chainId),
+		Nonce:      3,
+		GasTipCap:  uint256.NewInt(1_000_000_000),
+		GasFeeCap:  uint256.NewInt(30_000_000_000),
+		Gas:        21000,
+		To:         common.HexToAddress("0xFF00000000000000000000000000000000000010"),
+		Value:      uint256.NewInt(0),
+		BlobFeeCap: uint256.NewInt(1_000_000_000),
+		BlobHashes: sidecar.BlobHashes(),
+		Sidecar:    sidecar,
+	})
+}
+
+func TestSignTransactionForChain_Should_Sign_Blob_Transaction_With_Sidecar(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	chainId := big.NewInt(11155111)
+	tx := newTestBlobTransaction(t, chainId)
+
+	// when
+	signedTx, err := provider.SignTransactionForChain(context.Background(), keyId, chainId, tx)
+
+	// then
+	assert.NoError(t, err)
+	sender, err := types.Sender(types.NewCancunSigner(chainId), signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), sender)
+	assert.Equal(t, tx.BlobHashes(), signedTx.BlobHashes())
+	assert.NotNil(t, signedTx.BlobTxSidecar())
+	assert.Equal(t, tx.BlobTxSidecar().Commitments, signedTx.BlobTxSidecar().Commitments)
+}
+
+func TestGetWalletTransactor_Should_Sign_Blob_Transaction(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	chainId := big.NewInt(11155111)
+	opts, err := provider.GetWalletTransactor(context.Background(), keyId, chainId)
+	assert.NoError(t, err)
+
+	// when
+	signedTx, err := opts.Signer(opts.From, newTestBlobTransaction(t, chainId))
+
+	// then
+	assert.NoError(t, err)
+	sender, err := types.Sender(types.NewCancunSigner(chainId), signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, opts.From, sender)
+	assert.NotNil(t, signedTx.BlobTxSidecar())
+}
+
+func TestSignTransactionForChain_Should_Sign_Dynamic_Fee_Transaction(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+
+	// when
+	signedTx, err := provider.SignTransactionForChain(context.Background(), keyId, big.NewInt(1), newTestTransaction())
+
+	// then
+	assert.NoError(t, err)
+	sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), sender)
+}
+
+func TestSignTransaction(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	signer := types.NewLondonSigner(big.NewInt(1))
+
+	// when
+	signedTx, err := provider.SignTransaction(context.Background(), keyId, signer, newTestTransaction())
+
+	// then
+	assert.NoError(t, err)
+	sender, err := types.Sender(signer, signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), sender)
+}
+
+func TestSignTransaction_Should_Normalize_High_S_Signatures(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	client.SetHighS(true)
+	provider := kmswallet.NewProvider(client, nil)
+	signer := types.NewLondonSigner(big.NewInt(1))
+
+	// when
+	signedTx, err := provider.SignTransaction(context.Background(), keyId, signer, newTestTransaction())
+
+	// then
+	assert.NoError(t, err)
+	_, _, s := signedTx.RawSignatureValues()
+	assert.True(t, s.Cmp(new(big.Int).Rsh(crypto.S256().Params().N, 1)) <= 0)
+
+	sender, err := types.Sender(signer, signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), sender)
+}
+
+func TestSignTransaction_Should_Sign_Legacy_Transaction_With_Homestead_Signer(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	to := common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(1), Gas: 21000, To: &to, Value: big.NewInt(1)})
+
+	// when
+	signedTx, err := provider.SignTransaction(context.Background(), keyId, types.HomesteadSigner{}, tx)
+
+	// then
+	assert.NoError(t, err)
+	assert.False(t, signedTx.Protected())
+	sender, err := types.Sender(types.HomesteadSigner{}, signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), sender)
+}
+
+func TestSignTransactionByAlias(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	client.AddAlias("alias", keyId)
+	provider := kmswallet.NewProvider(client, nil)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+
+	// when
+	signedTx, err := provider.SignTransactionByAlias(context.Background(), "alias", signer, newTestTransaction())
+
+	// then
+	assert.NoError(t, err)
+	sender, err := types.Sender(signer, signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), sender)
+}
@@ -0,0 +1,355 @@
+package kmswallet
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+	siweVersion      = "1"
+	siweNonceLength  = 17
+	siweNonceChars   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+var (
+	ErrInvalidSiweMessage = errors.New("invalid SIWE message")
+	ErrSiweVerification   = errors.New("SIWE message verification failed")
+)
+
+// SiweMessage is an EIP-4361 Sign-In with Ethereum message.
+type SiweMessage struct {
+	Scheme         string
+	Domain         string
+	Address        common.Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainId        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestId      string
+	Resources      []string
+}
+
+type SiweVerifyInput struct {
+	// Domain, Nonce and ChainId are checked when they are set.
+	Domain  string
+	Nonce   string
+	ChainId int64
+	// Time is the time the validity window is checked against; time.Now() when it is zero.
+	Time time.Time
+}
+
+// NewSiweNonce returns a random alphanumeric nonce suitable for SiweMessage.Nonce.
+func NewSiweNonce() (string, error) {
+	nonce := make([]byte, siweNonceLength)
+	max := big.NewInt(int64(len(siweNonceChars)))
+	for i := range nonce {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		nonce[i] = siweNonceChars[n.Int64()]
+	}
+
+	return string(nonce), nil
+}
+
+// String returns the canonical EIP-4361 text of the message, which is what gets signed.
+func (m SiweMessage) String() string {
+	var builder strings.Builder
+	if m.Scheme != "" {
+		builder.WriteString(m.Scheme + "://")
+	}
+
+	builder.WriteString(m.Domain + siweHeaderSuffix + "\n")
+	builder.WriteString(m.Address.Hex() + "\n\n")
+	if m.Statement != "" {
+		builder.WriteString(m.Statement + "\n")
+	}
+
+	builder.WriteString("\n")
+	builder.WriteString("URI: " + m.URI + "\n")
+	builder.WriteString("Version: " + m.Version + "\n")
+	builder.WriteString("Chain ID: " + strconv.FormatInt(m.ChainId, 10) + "\n")
+	builder.WriteString("Nonce: " + m.Nonce + "\n")
+	builder.WriteString("Issued At: " + m.IssuedAt.UTC().Format(time.RFC3339Nano))
+	if m.ExpirationTime != nil {
+		builder.WriteString("\nExpiration Time: " + m.ExpirationTime.UTC().Format(time.RFC3339Nano))
+	}
+
+	if m.NotBefore != nil {
+		builder.WriteString("\nNot Before: " + m.NotBefore.UTC().Format(time.RFC3339Nano))
+	}
+
+	if m.RequestId != "" {
+		builder.WriteString("\nRequest ID: " + m.RequestId)
+	}
+
+	if len(m.Resources) > 0 {
+		builder.WriteString("\nResources:")
+		for _, resource := range m.Resources {
+			builder.WriteString("\n- " + resource)
+		}
+	}
+
+	return builder.String()
+}
+
+func (m SiweMessage) validate() error {
+	switch {
+	case m.Domain == "":
+		return fmt.Errorf("%w: domain is required", ErrInvalidSiweMessage)
+	case strings.ContainsAny(m.Scheme+m.Domain, " \t"):
+		return fmt.Errorf("%w: scheme and domain can not contain white space", ErrInvalidSiweMessage)
+	case m.URI == "":
+		return fmt.Errorf("%w: uri is required", ErrInvalidSiweMessage)
+	case m.Version != siweVersion:
+		return fmt.Errorf("%w: unsupported version: %q", ErrInvalidSiweMessage, m.Version)
+	case len(m.Nonce) < 8 || strings.Trim(m.Nonce, siweNonceChars) != "":
+		return fmt.Errorf("%w: nonce is required to be at least 8 alphanumeric characters", ErrInvalidSiweMessage)
+	case m.IssuedAt.IsZero():
+		return fmt.Errorf("%w: issued at is required", ErrInvalidSiweMessage)
+	}
+
+	fields := []string{m.Scheme, m.Domain, m.Statement, m.URI, m.RequestId}
+	for _, value := range append(fields, m.Resources...) {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%w: fields can not contain line breaks: %q", ErrInvalidSiweMessage, value)
+		}
+	}
+
+	if err := validateSiweURI(m.URI); err != nil {
+		return fmt.Errorf("%w: invalid uri: %q", ErrInvalidSiweMessage, m.URI)
+	}
+
+	for _, resource := range m.Resources {
+		if err := validateSiweURI(resource); err != nil {
+			return fmt.Errorf("%w: invalid resource: %q", ErrInvalidSiweMessage, resource)
+		}
+	}
+
+	return nil
+}
+
+// validateSiweURI checks that uri is an absolute RFC 3986 URI.
+func validateSiweURI(uri string) error {
+	if strings.ContainsAny(uri, " \t\r\n") {
+		return errors.New("uri can not contain white space")
+	}
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+
+	if parsed.Scheme == "" {
+		return errors.New("uri is required to have a scheme")
+	}
+
+	return nil
+}
+
+// ParseSiweMessage parses the canonical EIP-4361 text of a message.
+func ParseSiweMessage(text string) (*SiweMessage, error) {
+	lines := strings.Split(text, "\n")
+	if len(lines) < 8 || !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidSiweMessage)
+	}
+
+	message := &SiweMessage{}
+	message.Domain = strings.TrimSuffix(lines[0], siweHeaderSuffix)
+	if scheme, domain, found := strings.Cut(message.Domain, "://"); found {
+		message.Scheme, message.Domain = scheme, domain
+	}
+
+	if !common.IsHexAddress(lines[1]) || common.HexToAddress(lines[1]).Hex() != lines[1] {
+		return nil, fmt.Errorf("%w: address is required to be EIP-55 checksummed: %q", ErrInvalidSiweMessage, lines[1])
+	}
+
+	message.Address = common.HexToAddress(lines[1])
+	if lines[2] != "" {
+		return nil, fmt.Errorf("%w: missing empty line after address", ErrInvalidSiweMessage)
+	}
+
+	next := 3
+	if lines[next] != "" {
+		message.Statement = lines[next]
+		next++
+		if next >= len(lines) || lines[next] != "" {
+			return nil, fmt.Errorf("%w: missing empty line after statement", ErrInvalidSiweMessage)
+		}
+	}
+
+	next++
+	fields := lines[next:]
+	required := []string{"URI: ", "Version: ", "Chain ID: ", "Nonce: ", "Issued At: "}
+	if len(fields) < len(required) {
+		return nil, fmt.Errorf("%w: missing fields", ErrInvalidSiweMessage)
+	}
+
+	values := make([]string, len(required))
+	for i, prefix := range required {
+		if !strings.HasPrefix(fields[i], prefix) {
+			return nil, fmt.Errorf("%w: expected %q field", ErrInvalidSiweMessage, strings.TrimSuffix(prefix, ": "))
+		}
+
+		values[i] = strings.TrimPrefix(fields[i], prefix)
+	}
+
+	message.URI = values[0]
+	message.Version = values[1]
+	message.Nonce = values[3]
+
+	chainId, err := strconv.ParseInt(values[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid chain id: %q", ErrInvalidSiweMessage, values[2])
+	}
+
+	message.ChainId = chainId
+	if message.IssuedAt, err = parseSiweTime(values[4]); err != nil {
+		return nil, err
+	}
+
+	optional := fields[len(required):]
+	if len(optional) > 0 && strings.HasPrefix(optional[0], "Expiration Time: ") {
+		expirationTime, err := parseSiweTime(strings.TrimPrefix(optional[0], "Expiration Time: "))
+		if err != nil {
+			return nil, err
+		}
+
+		message.ExpirationTime = &expirationTime
+		optional = optional[1:]
+	}
+
+	if len(optional) > 0 && strings.HasPrefix(optional[0], "Not Before: ") {
+		notBefore, err := parseSiweTime(strings.TrimPrefix(optional[0], "Not Before: "))
+		if err != nil {
+			return nil, err
+		}
+
+		message.NotBefore = &notBefore
+		optional = optional[1:]
+	}
+
+	if len(optional) > 0 && strings.HasPrefix(optional[0], "Request ID: ") {
+		message.RequestId = strings.TrimPrefix(optional[0], "Request ID: ")
+		optional = optional[1:]
+	}
+
+	if len(optional) > 0 && optional[0] == "Resources:" {
+		for _, line := range optional[1:] {
+			if !strings.HasPrefix(line, "- ") {
+				return nil, fmt.Errorf("%w: invalid resource: %q", ErrInvalidSiweMessage, line)
+			}
+
+			message.Resources = append(message.Resources, strings.TrimPrefix(line, "- "))
+		}
+
+		optional = nil
+	}
+
+	if len(optional) > 0 {
+		return nil, fmt.Errorf("%w: unexpected line: %q", ErrInvalidSiweMessage, optional[0])
+	}
+
+	if err = message.validate(); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// VerifySiweMessage parses text, checks that signature is its signer's signature, that it is within its validity
+// window and that it matches the expected values of input, and returns the parsed message.
+func VerifySiweMessage(text string, signature []byte, input SiweVerifyInput) (*SiweMessage, error) {
+	message, err := ParseSiweMessage(text)
+	if err != nil {
+		return nil, err
+	}
+
+	valid, err := VerifyMessageSignature(message.Address, []byte(text), signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if !valid {
+		return nil, fmt.Errorf("%w: signature does not belong to %s", ErrSiweVerification, message.Address.Hex())
+	}
+
+	now := input.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	switch {
+	case input.Domain != "" && message.Domain != input.Domain:
+		return nil, fmt.Errorf("%w: domain mismatch: %q", ErrSiweVerification, message.Domain)
+	case input.Nonce != "" && message.Nonce != input.Nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrSiweVerification)
+	case input.ChainId != 0 && message.ChainId != input.ChainId:
+		return nil, fmt.Errorf("%w: chain id mismatch: %d", ErrSiweVerification, message.ChainId)
+	case message.ExpirationTime != nil && !now.Before(*message.ExpirationTime):
+		return nil, fmt.Errorf("%w: message expired at %s", ErrSiweVerification, message.ExpirationTime.Format(time.RFC3339))
+	case message.NotBefore != nil && now.Before(*message.NotBefore):
+		return nil, fmt.Errorf("%w: message is not valid before %s", ErrSiweVerification, message.NotBefore.Format(time.RFC3339))
+	}
+
+	return message, nil
+}
+
+// SignSiweMessage fills in the wallet address, validates the message and signs its canonical text with SignMessage.
+// It returns the signed text together with the signature.
+func (c *provider) SignSiweMessage(ctx context.Context, keyId string, message SiweMessage) (string, []byte, error) {
+	wallet, err := c.GetWallet(ctx, keyId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	walletAddress := common.HexToAddress(wallet.Address)
+	if message.Address != (common.Address{}) && message.Address != walletAddress {
+		return "", nil, fmt.Errorf("%w: address %s does not belong to keyId: %s", ErrInvalidSiweMessage, message.Address.Hex(), keyId)
+	}
+
+	message.Address = walletAddress
+	if message.Version == "" {
+		message.Version = siweVersion
+	}
+
+	if message.IssuedAt.IsZero() {
+		message.IssuedAt = time.Now()
+	}
+
+	if err = message.validate(); err != nil {
+		return "", nil, err
+	}
+
+	text := message.String()
+	signature, err := c.SignMessage(ctx, keyId, []byte(text))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return text, signature, nil
+}
+
+func parseSiweTime(value string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid time: %q", ErrInvalidSiweMessage, value)
+	}
+
+	return parsed, nil
+}
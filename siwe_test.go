@@ -0,0 +1,208 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const siweExampleMessage = `example.com wants you to sign in with your Ethereum account:
+0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2
+
+I accept the ExampleOrg Terms of Service: https://example.com/tos
+
+URI: https://example.com/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756
+Issued At: 2021-09-30T16:25:24Z
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`
+
+func TestParseSiweMessage(t *testing.T) {
+	// when
+	message, err := kmswallet.ParseSiweMessage(siweExampleMessage)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", message.Domain)
+	assert.Equal(t, common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), message.Address)
+	assert.Equal(t, "I accept the ExampleOrg Terms of Service: https://example.com/tos", message.Statement)
+	assert.Equal(t, "https://example.com/login", message.URI)
+	assert.Equal(t, "1", message.Version)
+	assert.Equal(t, int64(1), message.ChainId)
+	assert.Equal(t, "32891756", message.Nonce)
+	assert.Equal(t, time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC), message.IssuedAt)
+	assert.Len(t, message.Resources, 2)
+	assert.Equal(t, siweExampleMessage, message.String())
+}
+
+func TestParseSiweMessage_Without_Statement_With_Optional_Fields(t *testing.T) {
+	// given
+	expirationTime := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	notBefore := time.Date(2021, 9, 30, 17, 0, 0, 0, time.UTC)
+	expected := kmswallet.SiweMessage{
+		Scheme:         "https",
+		Domain:         "example.com:8443",
+		Address:        common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		URI:            "https://example.com/login",
+		Version:        "1",
+		ChainId:        137,
+		Nonce:          "abcdEFGH1234",
+		IssuedAt:       time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC),
+		ExpirationTime: &expirationTime,
+		NotBefore:      &notBefore,
+		RequestId:      "request-1",
+	}
+
+	// when
+	text := expected.String()
+	message, err := kmswallet.ParseSiweMessage(text)
+
+	// then
+	assert.NoError(t, err)
+	assert.Contains(t, text, "https://example.com:8443 wants you to sign in with your Ethereum account:\n0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2\n\n\nURI: ")
+	assert.Equal(t, expected, *message)
+}
+
+func TestParseSiweMessage_Should_Reject_Invalid_Messages(t *testing.T) {
+	invalidMessages := map[string]string{
+		"missing header":       strings.Replace(siweExampleMessage, " wants you to sign in", " wants to sign in", 1),
+		"non checksum address": strings.Replace(siweExampleMessage, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", 1),
+		"unsupported version":  strings.Replace(siweExampleMessage, "Version: 1", "Version: 2", 1),
+		"short nonce":          strings.Replace(siweExampleMessage, "Nonce: 32891756", "Nonce: 1234", 1),
+		"invalid issued at":    strings.Replace(siweExampleMessage, "2021-09-30T16:25:24Z", "yesterday", 1),
+		"missing field":        strings.Replace(siweExampleMessage, "Chain ID: 1\n", "", 1),
+		"relative uri":         strings.Replace(siweExampleMessage, "URI: https://example.com/login", "URI: /login", 1),
+		"invalid resource":     strings.Replace(siweExampleMessage, "- https://example.com/my-web2-claim.json", "- https://example.com/my claim.json", 1),
+	}
+
+	for name, text := range invalidMessages {
+		// when
+		_, err := kmswallet.ParseSiweMessage(text)
+
+		// then
+		assert.ErrorIs(t, err, kmswallet.ErrInvalidSiweMessage, name)
+	}
+}
+
+func TestSignSiweMessage_And_VerifySiweMessage(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	nonce, err := kmswallet.NewSiweNonce()
+	assert.NoError(t, err)
+	expirationTime := time.Now().Add(time.Hour)
+
+	// when
+	text, signature, err := provider.SignSiweMessage(context.Background(), keyId, kmswallet.SiweMessage{
+		Domain:         "partner.example.com",
+		Statement:      "Sign in to the partner API",
+		URI:            "https://partner.example.com/auth",
+		ChainId:        1,
+		Nonce:          nonce,
+		ExpirationTime: &expirationTime,
+		Resources:      []string{"https://partner.example.com/orders"},
+	})
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text, "partner.example.com wants you to sign in with your Ethereum account:\n"+client.Address(keyId).Hex()+"\n"))
+
+	message, err := kmswallet.VerifySiweMessage(text, signature, kmswallet.SiweVerifyInput{
+		Domain:  "partner.example.com",
+		Nonce:   nonce,
+		ChainId: 1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), message.Address)
+}
+
+func TestVerifySiweMessage_Should_Reject_Invalid_Signatures_And_Expectations(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	expirationTime := time.Now().Add(time.Hour)
+	text, signature, err := provider.SignSiweMessage(context.Background(), keyId, kmswallet.SiweMessage{
+		Domain:         "partner.example.com",
+		URI:            "https://partner.example.com/auth",
+		ChainId:        1,
+		Nonce:          "abcdefgh1234",
+		ExpirationTime: &expirationTime,
+	})
+	assert.NoError(t, err)
+
+	// when
+	_, errTampered := kmswallet.VerifySiweMessage(strings.Replace(text, "Chain ID: 1", "Chain ID: 5", 1), signature, kmswallet.SiweVerifyInput{})
+	_, errDomain := kmswallet.VerifySiweMessage(text, signature, kmswallet.SiweVerifyInput{Domain: "evil.example.com"})
+	_, errNonce := kmswallet.VerifySiweMessage(text, signature, kmswallet.SiweVerifyInput{Nonce: "otherNonce123"})
+	_, errExpired := kmswallet.VerifySiweMessage(text, signature, kmswallet.SiweVerifyInput{Time: expirationTime.Add(time.Second)})
+
+	// then
+	assert.ErrorIs(t, errTampered, kmswallet.ErrSiweVerification)
+	assert.ErrorIs(t, errDomain, kmswallet.ErrSiweVerification)
+	assert.ErrorIs(t, errNonce, kmswallet.ErrSiweVerification)
+	assert.ErrorIs(t, errExpired, kmswallet.ErrSiweVerification)
+}
+
+func TestSignSiweMessage_Should_Reject_Foreign_Address(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+
+	// when
+	_, _, err := provider.SignSiweMessage(context.Background(), keyId, kmswallet.SiweMessage{
+		Domain:  "partner.example.com",
+		Address: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		URI:     "https://partner.example.com/auth",
+		ChainId: 1,
+		Nonce:   "abcdefgh1234",
+	})
+
+	// then
+	assert.ErrorIs(t, err, kmswallet.ErrInvalidSiweMessage)
+}
+
+func TestSignSiweMessage_Should_Reject_Line_Breaks_And_Invalid_URIs(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	valid := kmswallet.SiweMessage{
+		Domain:  "partner.example.com",
+		Address: client.Address(keyId),
+		URI:     "https://partner.example.com/auth",
+		ChainId: 1,
+		Nonce:   "abcdefgh1234",
+	}
+
+	invalidMessages := map[string]func(message *kmswallet.SiweMessage){
+		"domain":           func(m *kmswallet.SiweMessage) { m.Domain = "evil.example.com\nChain ID: 5" },
+		"domain space":     func(m *kmswallet.SiweMessage) { m.Domain = "partner.example.com wants" },
+		"scheme":           func(m *kmswallet.SiweMessage) { m.Scheme = "https\r" },
+		"statement":        func(m *kmswallet.SiweMessage) { m.Statement = "hello\r\nChain ID: 5" },
+		"uri":              func(m *kmswallet.SiweMessage) { m.URI = "https://partner.example.com\nChain ID: 5" },
+		"request id":       func(m *kmswallet.SiweMessage) { m.RequestId = "1\nResources:" },
+		"resource":         func(m *kmswallet.SiweMessage) { m.Resources = []string{"https://partner.example.com\n- ipfs://evil"} },
+		"relative uri":     func(m *kmswallet.SiweMessage) { m.URI = "partner.example.com/auth" },
+		"invalid uri":      func(m *kmswallet.SiweMessage) { m.URI = "https://partner.example.com/%zz" },
+		"invalid resource": func(m *kmswallet.SiweMessage) { m.Resources = []string{"orders"} },
+	}
+
+	for name, invalidate := range invalidMessages {
+		message := valid
+		invalidate(&message)
+
+		// when
+		_, _, err := provider.SignSiweMessage(context.Background(), keyId, message)
+
+		// then
+		assert.ErrorIs(t, err, kmswallet.ErrInvalidSiweMessage, name)
+	}
+
+	assert.Equal(t, 0, client.SignCalls())
+}
@@ -0,0 +1,118 @@
+package kmswallet
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ether_types "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// walletSigners maps the address of every TransactOpts whose Signer is a walletSigner to its signerRegistration, so
+// that WithContext finds the walletSigner without calling the Signer. The address is kept as a uintptr, which does
+// not keep the opts alive, and the entry is removed once the Signer is garbage collected.
+var walletSigners sync.Map
+
+type signerRegistration struct {
+	signer *walletSigner
+	key    uintptr
+}
+
+// signerHandle is held by the Signer of a registration. The opts and their Signer refer to each other, which keeps
+// a finalizer on the opts from running, so the finalizer is set on the handle instead.
+type signerHandle struct {
+	registration *signerRegistration
+}
+
+// walletSigner signs the transactions of a transactor with the KMS key. check, when set, vets every transaction
+// before it is signed.
+type walletSigner struct {
+	c       *provider
+	ctx     context.Context
+	keyId   string
+	chainId *big.Int
+	from    common.Address
+	check   func(tx *ether_types.Transaction) error
+}
+
+// WithContext returns a copy of opts whose signatures use ctx, for transactors of GetWalletTransactor,
+// GetManagedTransactor and GetTransactorByName. Copying the opts and setting Context on the copy is not enough, as
+// the Signer reads the Context of the opts it was created with. Copies of other transactors, including opts copied by
+// hand, only get Context set; their Signer is never called.
+func WithContext(opts *bind.TransactOpts, ctx context.Context) *bind.TransactOpts {
+	copied := &bind.TransactOpts{}
+	*copied = *opts
+	copied.Context = ctx
+
+	value, _ := walletSigners.Load(uintptr(unsafe.Pointer(opts)))
+	if registration, ok := value.(*signerRegistration); ok && registration.signer.from == opts.From {
+		registration.signer.bind(copied)
+	}
+
+	return copied
+}
+
+func (c *provider) newTransactor(ctx context.Context, keyId string, chainId *big.Int, check func(tx *ether_types.Transaction) error) (*bind.TransactOpts, error) {
+	publicKey, err := c.getPublicKey(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if chainId == nil {
+		return nil, bind.ErrNoChainID
+	}
+
+	signer := &walletSigner{
+		c:       c,
+		ctx:     context.WithoutCancel(ctx),
+		keyId:   keyId,
+		chainId: chainId,
+		from:    crypto.PubkeyToAddress(*publicKey),
+		check:   check,
+	}
+
+	opts := &bind.TransactOpts{From: signer.from}
+	signer.bind(opts)
+	return opts, nil
+}
+
+// bind sets the Signer of opts and registers it for WithContext.
+func (s *walletSigner) bind(opts *bind.TransactOpts) {
+	registration := &signerRegistration{signer: s, key: uintptr(unsafe.Pointer(opts))}
+	walletSigners.Store(registration.key, registration)
+
+	handle := &signerHandle{registration: registration}
+	runtime.SetFinalizer(handle, func(handle *signerHandle) {
+		walletSigners.CompareAndDelete(handle.registration.key, handle.registration)
+	})
+
+	opts.Signer = s.signerFn(opts, handle)
+}
+
+// signerFn returns the Signer of opts, which signs with opts.Context when it is set.
+func (s *walletSigner) signerFn(opts *bind.TransactOpts, handle *signerHandle) bind.SignerFn {
+	return func(address common.Address, tx *ether_types.Transaction) (*ether_types.Transaction, error) {
+		defer runtime.KeepAlive(handle)
+
+		if address != s.from {
+			return nil, bind.ErrNotAuthorized
+		}
+
+		if s.check != nil {
+			if err := s.check(tx); err != nil {
+				return nil, err
+			}
+		}
+
+		signCtx := opts.Context
+		if signCtx == nil {
+			signCtx = s.ctx
+		}
+
+		return s.c.signTransaction(signCtx, s.keyId, transactionSigner(s.chainId, tx), tx)
+	}
+}
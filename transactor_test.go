@@ -0,0 +1,198 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestTransaction() *types.Transaction {
+	to := common.HexToAddress("0x5B1a501FAB5c6D78CBd61F31f3B4B42286Bcf118")
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     1,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(100),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(1),
+	})
+}
+
+func TestGetWalletTransactor_Should_Sign_When_Creation_Context_Is_Cancelled(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		return ctx.Err()
+	})
+
+	opts, err := provider.GetWalletTransactor(ctx, keyId, big.NewInt(1))
+	assert.NoError(t, err)
+	cancel()
+
+	// when
+	signedTx, err := opts.Signer(opts.From, newTestTransaction())
+
+	// then
+	assert.NoError(t, err)
+	sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), sender)
+}
+
+func TestGetWalletTransactor_Should_Use_Transact_Opts_Context(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		return ctx.Err()
+	})
+
+	opts, err := provider.GetWalletTransactor(context.Background(), keyId, big.NewInt(1))
+	assert.NoError(t, err)
+
+	txCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts.Context = txCtx
+
+	// when
+	_, err = opts.Signer(opts.From, newTestTransaction())
+
+	// then
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGetWalletTransactor_Should_Time_Out_Sign_When_Sign_Timeout_Is_Set(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithSignTimeout(10*time.Millisecond))
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	opts, err := provider.GetWalletTransactor(context.Background(), keyId, big.NewInt(1))
+	assert.NoError(t, err)
+
+	// when
+	_, err = opts.Signer(opts.From, newTestTransaction())
+
+	// then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithContext_Should_Sign_Copied_Opts_With_The_Call_Context(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		return ctx.Err()
+	})
+
+	opts, err := provider.GetWalletTransactor(context.Background(), keyId, big.NewInt(1))
+	assert.NoError(t, err)
+
+	callCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// when
+	copied := *opts
+	copied.Context = callCtx
+	_, copiedErr := copied.Signer(copied.From, newTestTransaction())
+
+	withContext := kmswallet.WithContext(opts, callCtx)
+	_, withContextErr := withContext.Signer(withContext.From, newTestTransaction())
+	_, originalErr := opts.Signer(opts.From, newTestTransaction())
+
+	// then
+	assert.NoError(t, copiedErr)
+	assert.ErrorIs(t, withContextErr, context.Canceled)
+	assert.Equal(t, callCtx, withContext.Context)
+	assert.Nil(t, opts.Context)
+	assert.NoError(t, originalErr)
+}
+
+func TestWithContext_Should_Keep_Wallet_Policy(t *testing.T) {
+	// given
+	client, keyId := newRegistryKMSClient(t, kmstypes.KeyStateEnabled)
+	provider := kmswallet.NewProvider(client, nil, kmswallet.WithRegistry(testRegistry(client.Address(keyId))))
+	opts, err := provider.GetTransactorByName(context.Background(), "treasury", big.NewInt(1))
+	assert.NoError(t, err)
+
+	// when
+	withContext := kmswallet.WithContext(opts, context.Background())
+	_, policyErr := withContext.Signer(withContext.From, newTestTransaction())
+
+	// then
+	assert.ErrorIs(t, policyErr, kmswallet.ErrPolicyViolation)
+}
+
+func TestWithContext_Should_Copy_Other_Transactors(t *testing.T) {
+	// given
+	privateKey, _ := crypto.GenerateKey()
+	opts, _ := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(1))
+	ctx := context.Background()
+
+	// when
+	withContext := kmswallet.WithContext(opts, ctx)
+	signedTx, err := withContext.Signer(withContext.From, newTestTransaction())
+
+	// then
+	assert.NoError(t, err)
+	assert.NotNil(t, signedTx)
+	assert.Equal(t, ctx, withContext.Context)
+}
+
+func TestWithContext_Should_Not_Call_Other_Signers(t *testing.T) {
+	// given
+	calls := 0
+	opts := &bind.TransactOpts{
+		From: common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582"),
+		Signer: func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			calls++
+			return tx, nil
+		},
+	}
+
+	// when
+	withContext := kmswallet.WithContext(opts, context.Background())
+
+	// then
+	assert.Equal(t, 0, calls)
+	assert.NotNil(t, withContext.Signer)
+}
+
+func TestWithContext_Should_Sign_With_The_Latest_Context(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	client.SetSignHook(func(ctx context.Context, _ []byte) error {
+		return ctx.Err()
+	})
+
+	opts, err := provider.GetWalletTransactor(context.Background(), keyId, big.NewInt(1))
+	assert.NoError(t, err)
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// when
+	first := kmswallet.WithContext(opts, context.Background())
+	second := kmswallet.WithContext(first, cancelledCtx)
+	_, firstErr := first.Signer(first.From, newTestTransaction())
+	_, secondErr := second.Signer(second.From, newTestTransaction())
+
+	// then
+	assert.NoError(t, firstErr)
+	assert.ErrorIs(t, secondErr, context.Canceled)
+}
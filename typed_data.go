@@ -0,0 +1,30 @@
+package kmswallet
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// SignTypedData signs the EIP-712 hash of typedData.
+func (c *provider) SignTypedData(ctx context.Context, keyId string, typedData apitypes.TypedData, opts ...SignOption) ([]byte, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := c.signDigest(ctx, keyId, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return formatSignature(signature, opts), nil
+}
+
+func (c *provider) SignTypedDataByAlias(ctx context.Context, alias string, typedData apitypes.TypedData, opts ...SignOption) ([]byte, error) {
+	keyId, err := c.GetKeyIdByAlias(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.SignTypedData(ctx, keyId, typedData, opts...)
+}
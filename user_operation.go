@@ -0,0 +1,189 @@
+package kmswallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+)
+
+var ErrInvalidUserOperation = errors.New("invalid user operation")
+
+var (
+	EntryPointV06Address = common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789")
+	EntryPointV07Address = common.HexToAddress("0x0000000071727De22E5E9d8BAb0edAC6f535Da03")
+)
+
+// UserOperation is an ERC-4337 user operation of EntryPoint v0.6.
+type UserOperation struct {
+	Sender               common.Address
+	Nonce                *big.Int
+	InitCode             []byte
+	CallData             []byte
+	CallGasLimit         *big.Int
+	VerificationGasLimit *big.Int
+	PreVerificationGas   *big.Int
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	PaymasterAndData     []byte
+	Signature            []byte
+}
+
+// PackedUserOperation is an ERC-4337 user operation of EntryPoint v0.7, with the gas limits and fees packed into
+// AccountGasLimits and GasFees (see PackAccountGasLimits, PackGasFees and PackPaymasterAndData).
+type PackedUserOperation struct {
+	Sender             common.Address
+	Nonce              *big.Int
+	InitCode           []byte
+	CallData           []byte
+	AccountGasLimits   [32]byte
+	PreVerificationGas *big.Int
+	GasFees            [32]byte
+	PaymasterAndData   []byte
+	Signature          []byte
+}
+
+// Hash returns the user operation hash of EntryPoint v0.6: keccak(abi.encode(keccak(pack(op)), entryPoint, chainId)).
+// Numbers that are negative or do not fit their uint256 field are refused with ErrInvalidUserOperation.
+func (op *UserOperation) Hash(entryPoint common.Address, chainId *big.Int) (common.Hash, error) {
+	var w wordPacker
+	packed := crypto.Keccak256(
+		common.LeftPadBytes(op.Sender.Bytes(), 32),
+		w.uint256("nonce", op.Nonce),
+		crypto.Keccak256(op.InitCode),
+		crypto.Keccak256(op.CallData),
+		w.uint256("callGasLimit", op.CallGasLimit),
+		w.uint256("verificationGasLimit", op.VerificationGasLimit),
+		w.uint256("preVerificationGas", op.PreVerificationGas),
+		w.uint256("maxFeePerGas", op.MaxFeePerGas),
+		w.uint256("maxPriorityFeePerGas", op.MaxPriorityFeePerGas),
+		crypto.Keccak256(op.PaymasterAndData),
+	)
+
+	return w.userOperationHash(packed, entryPoint, chainId)
+}
+
+// Hash returns the user operation hash of EntryPoint v0.7: keccak(abi.encode(keccak(pack(op)), entryPoint, chainId)).
+// Numbers that are negative or do not fit their uint256 field are refused with ErrInvalidUserOperation.
+func (op *PackedUserOperation) Hash(entryPoint common.Address, chainId *big.Int) (common.Hash, error) {
+	var w wordPacker
+	packed := crypto.Keccak256(
+		common.LeftPadBytes(op.Sender.Bytes(), 32),
+		w.uint256("nonce", op.Nonce),
+		crypto.Keccak256(op.InitCode),
+		crypto.Keccak256(op.CallData),
+		op.AccountGasLimits[:],
+		w.uint256("preVerificationGas", op.PreVerificationGas),
+		op.GasFees[:],
+		crypto.Keccak256(op.PaymasterAndData),
+	)
+
+	return w.userOperationHash(packed, entryPoint, chainId)
+}
+
+// PackAccountGasLimits packs the v0.7 accountGasLimits field: verificationGasLimit in the high and callGasLimit in
+// the low 16 bytes. Limits that are negative or do not fit 128 bits are refused with ErrInvalidUserOperation.
+func PackAccountGasLimits(verificationGasLimit *big.Int, callGasLimit *big.Int) ([32]byte, error) {
+	return packUint128Pair("verificationGasLimit", verificationGasLimit, "callGasLimit", callGasLimit)
+}
+
+// PackGasFees packs the v0.7 gasFees field: maxPriorityFeePerGas in the high and maxFeePerGas in the low 16 bytes.
+// Fees that are negative or do not fit 128 bits are refused with ErrInvalidUserOperation.
+func PackGasFees(maxPriorityFeePerGas *big.Int, maxFeePerGas *big.Int) ([32]byte, error) {
+	return packUint128Pair("maxPriorityFeePerGas", maxPriorityFeePerGas, "maxFeePerGas", maxFeePerGas)
+}
+
+// PackPaymasterAndData packs the v0.7 paymasterAndData field: paymaster, its uint128 verification and post-op gas
+// limits and data.
+func PackPaymasterAndData(paymaster common.Address, verificationGasLimit *big.Int, postOpGasLimit *big.Int, data []byte) ([]byte, error) {
+	gasLimits, err := packUint128Pair("paymasterVerificationGasLimit", verificationGasLimit, "paymasterPostOpGasLimit", postOpGasLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	packed := append(paymaster.Bytes(), gasLimits[:]...)
+	return append(packed, data...), nil
+}
+
+// SignUserOperation signs the v0.6 user operation hash as the owner of a smart account validating
+// toEthSignedMessageHash(userOpHash), such as SimpleAccount. The signature is returned with V as 27 or 28, the
+// operation itself is not modified.
+func (c *provider) SignUserOperation(ctx context.Context, keyId string, op *UserOperation, entryPoint common.Address, chainId *big.Int) ([]byte, error) {
+	hash, err := op.Hash(entryPoint, chainId)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.SignMessage(ctx, keyId, hash.Bytes())
+}
+
+// SignPackedUserOperation signs the v0.7 user operation hash like SignUserOperation.
+func (c *provider) SignPackedUserOperation(ctx context.Context, keyId string, op *PackedUserOperation, entryPoint common.Address, chainId *big.Int) ([]byte, error) {
+	hash, err := op.Hash(entryPoint, chainId)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.SignMessage(ctx, keyId, hash.Bytes())
+}
+
+// wordPacker encodes numbers as big-endian words and keeps the first number that does not fit its word.
+type wordPacker struct {
+	err error
+}
+
+func (w *wordPacker) uint256(name string, value *big.Int) []byte {
+	word := make([]byte, 32)
+	if err := checkUint(name, value, 256); err != nil {
+		if w.err == nil {
+			w.err = err
+		}
+
+		return word
+	}
+
+	if value != nil {
+		value.FillBytes(word)
+	}
+
+	return word
+}
+
+func (w *wordPacker) userOperationHash(packed []byte, entryPoint common.Address, chainId *big.Int) (common.Hash, error) {
+	chainIdWord := w.uint256("chainId", chainId)
+	if w.err != nil {
+		return common.Hash{}, w.err
+	}
+
+	return crypto.Keccak256Hash(packed, common.LeftPadBytes(entryPoint.Bytes(), 32), chainIdWord), nil
+}
+
+func packUint128Pair(highName string, high *big.Int, lowName string, low *big.Int) ([32]byte, error) {
+	var packed [32]byte
+	if err := checkUint(highName, high, 128); err != nil {
+		return packed, err
+	}
+
+	if err := checkUint(lowName, low, 128); err != nil {
+		return packed, err
+	}
+
+	if high != nil {
+		high.FillBytes(packed[:16])
+	}
+	if low != nil {
+		low.FillBytes(packed[16:])
+	}
+	return packed, nil
+}
+
+// checkUint returns ErrInvalidUserOperation unless value, nil being zero, is an unsigned integer of at most bits.
+func checkUint(name string, value *big.Int, bits int) error {
+	if value != nil && (value.Sign() < 0 || value.BitLen() > bits) {
+		return fmt.Errorf("%w: %s %s does not fit uint%d", ErrInvalidUserOperation, name, value, bits)
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func abiEncode(t *testing.T, types []string, values ...interface{}) []byte {
+	arguments := make(abi.Arguments, len(types))
+	for i, typeName := range types {
+		argumentType, err := abi.NewType(typeName, "", nil)
+		assert.NoError(t, err)
+		arguments[i] = abi.Argument{Type: argumentType}
+	}
+
+	encoded, err := arguments.Pack(values...)
+	assert.NoError(t, err)
+	return encoded
+}
+
+func envelopeHash(t *testing.T, packed []byte, entryPoint common.Address, chainId *big.Int) common.Hash {
+	return crypto.Keccak256Hash(abiEncode(t, []string{"bytes32", "address", "uint256"}, crypto.Keccak256Hash(packed), entryPoint, chainId))
+}
+
+func newTestUserOperation() *kmswallet.UserOperation {
+	return &kmswallet.UserOperation{
+		Sender:               common.HexToAddress("0x9406Cc6185a346906296840746125a0E44976454"),
+		Nonce:                big.NewInt(5),
+		InitCode:             hexutil.MustDecode("0x9406cc6185a346906296840746125a0e449764545fbfb9cf"),
+		CallData:             hexutil.MustDecode("0xb61d27f6"),
+		CallGasLimit:         big.NewInt(35_000),
+		VerificationGasLimit: big.NewInt(70_000),
+		PreVerificationGas:   big.NewInt(21_000),
+		MaxFeePerGas:         big.NewInt(30_000_000_000),
+		MaxPriorityFeePerGas: big.NewInt(1_500_000_000),
+		PaymasterAndData:     nil,
+	}
+}
+
+func mustPack(t *testing.T) func(packed [32]byte, err error) [32]byte {
+	return func(packed [32]byte, err error) [32]byte {
+		assert.NoError(t, err)
+		return packed
+	}
+}
+
+func TestEntryPointAddresses(t *testing.T) {
+	assert.Equal(t, "0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789", kmswallet.EntryPointV06Address.Hex())
+	assert.Equal(t, common.HexToAddress("0x0000000071727De22E5E9d8BAb0edAC6f535Da03"), kmswallet.EntryPointV07Address)
+	assert.Equal(t, "0x0000000071727dE22e5e9D8BaB0EDAC6f535DA03", kmswallet.EntryPointV07Address.Hex())
+}
+
+func TestUserOperation_Hash(t *testing.T) {
+	// given
+	op := newTestUserOperation()
+	chainId := big.NewInt(11155111)
+	packed := abiEncode(t,
+		[]string{"address", "uint256", "bytes32", "bytes32", "uint256", "uint256", "uint256", "uint256", "uint256", "bytes32"},
+		op.Sender, op.Nonce, crypto.Keccak256Hash(op.InitCode), crypto.Keccak256Hash(op.CallData), op.CallGasLimit,
+		op.VerificationGasLimit, op.PreVerificationGas, op.MaxFeePerGas, op.MaxPriorityFeePerGas, crypto.Keccak256Hash(op.PaymasterAndData),
+	)
+
+	// when
+	hash, err := op.Hash(kmswallet.EntryPointV06Address, chainId)
+	otherChainHash, _ := op.Hash(kmswallet.EntryPointV06Address, big.NewInt(1))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, envelopeHash(t, packed, common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789"), chainId), hash)
+	assert.Equal(t, "0x311d3ebdf9ca71d89b12701002c67acd454eb9b02a774dd14120e39b1d286b86", hash.Hex())
+	assert.NotEqual(t, hash, otherChainHash)
+}
+
+func TestPackedUserOperation_Hash(t *testing.T) {
+	// given
+	chainId := big.NewInt(8453)
+	paymaster := common.HexToAddress("0x00000000000000fB866DaAA79352cC568a005D96")
+	paymasterAndData, err := kmswallet.PackPaymasterAndData(paymaster, big.NewInt(50_000), big.NewInt(10_000), []byte{0xca, 0xfe})
+	assert.NoError(t, err)
+	op := &kmswallet.PackedUserOperation{
+		Sender:             common.HexToAddress("0x9406Cc6185a346906296840746125a0E44976454"),
+		Nonce:              new(big.Int).Lsh(big.NewInt(1), 64),
+		CallData:           hexutil.MustDecode("0xb61d27f6"),
+		AccountGasLimits:   mustPack(t)(kmswallet.PackAccountGasLimits(big.NewInt(70_000), big.NewInt(35_000))),
+		PreVerificationGas: big.NewInt(21_000),
+		GasFees:            mustPack(t)(kmswallet.PackGasFees(big.NewInt(1_500_000_000), big.NewInt(30_000_000_000))),
+		PaymasterAndData:   paymasterAndData,
+	}
+
+	accountGasLimits := new(big.Int).Or(new(big.Int).Lsh(big.NewInt(70_000), 128), big.NewInt(35_000))
+	gasFees := new(big.Int).Or(new(big.Int).Lsh(big.NewInt(1_500_000_000), 128), big.NewInt(30_000_000_000))
+	packed := abiEncode(t,
+		[]string{"address", "uint256", "bytes32", "bytes32", "bytes32", "uint256", "bytes32", "bytes32"},
+		op.Sender, op.Nonce, crypto.Keccak256Hash(op.InitCode), crypto.Keccak256Hash(op.CallData),
+		common.BigToHash(accountGasLimits), op.PreVerificationGas, common.BigToHash(gasFees), crypto.Keccak256Hash(op.PaymasterAndData),
+	)
+
+	// when
+	hash, err := op.Hash(kmswallet.EntryPointV07Address, chainId)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, envelopeHash(t, packed, common.HexToAddress("0x0000000071727De22E5E9d8BAb0edAC6f535Da03"), chainId), hash)
+	assert.Equal(t, "0x434e817d4fddf71836c66719656a6c823566803ab5ccece12fc8383326482423", hash.Hex())
+	assert.Equal(t, "0x00000000000000fb866daaa79352cc568a005d96"+
+		"0000000000000000000000000000c350"+"00000000000000000000000000002710"+"cafe", hexutil.Encode(op.PaymasterAndData))
+}
+
+func TestSignUserOperation(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	op := newTestUserOperation()
+	chainId := big.NewInt(1)
+	hash, _ := op.Hash(kmswallet.EntryPointV06Address, chainId)
+
+	// when
+	signature, err := provider.SignUserOperation(context.Background(), keyId, op, kmswallet.EntryPointV06Address, chainId)
+
+	// then
+	assert.NoError(t, err)
+	assert.Nil(t, op.Signature)
+	recovered, err := kmswallet.RecoverAddress(accounts.TextHash(hash.Bytes()), signature)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), recovered)
+}
+
+func TestSignPackedUserOperation(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	op := &kmswallet.PackedUserOperation{
+		Sender:             common.HexToAddress("0x9406Cc6185a346906296840746125a0E44976454"),
+		Nonce:              big.NewInt(0),
+		AccountGasLimits:   mustPack(t)(kmswallet.PackAccountGasLimits(big.NewInt(70_000), big.NewInt(35_000))),
+		PreVerificationGas: big.NewInt(21_000),
+		GasFees:            mustPack(t)(kmswallet.PackGasFees(big.NewInt(1_500_000_000), big.NewInt(30_000_000_000))),
+	}
+	chainId := big.NewInt(1)
+	hash, _ := op.Hash(kmswallet.EntryPointV07Address, chainId)
+
+	// when
+	signature, err := provider.SignPackedUserOperation(context.Background(), keyId, op, kmswallet.EntryPointV07Address, chainId)
+
+	// then
+	assert.NoError(t, err)
+	recovered, err := kmswallet.RecoverAddress(accounts.TextHash(hash.Bytes()), signature)
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), recovered)
+}
+
+func TestUserOperation_Should_Refuse_Numbers_That_Do_Not_Fit(t *testing.T) {
+	// given
+	uint128Max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	tooLarge := new(big.Int).Lsh(big.NewInt(1), 128)
+	negativeNonce := newTestUserOperation()
+	negativeNonce.Nonce = big.NewInt(-1)
+	oversizedFee := newTestUserOperation()
+	oversizedFee.MaxFeePerGas = new(big.Int).Lsh(big.NewInt(1), 256)
+
+	// when
+	maxLimits, maxErr := kmswallet.PackAccountGasLimits(uint128Max, uint128Max)
+	_, tooLargeErr := kmswallet.PackAccountGasLimits(tooLarge, big.NewInt(1))
+	_, negativeErr := kmswallet.PackGasFees(big.NewInt(1), big.NewInt(-1))
+	_, paymasterErr := kmswallet.PackPaymasterAndData(common.Address{}, big.NewInt(1), tooLarge, nil)
+	_, nonceErr := negativeNonce.Hash(kmswallet.EntryPointV06Address, big.NewInt(1))
+	_, feeErr := oversizedFee.Hash(kmswallet.EntryPointV06Address, big.NewInt(1))
+	_, chainIdErr := newTestUserOperation().Hash(kmswallet.EntryPointV06Address, big.NewInt(-1))
+
+	// then
+	assert.NoError(t, maxErr)
+	assert.Equal(t, common.MaxHash, common.Hash(maxLimits))
+	assert.ErrorIs(t, tooLargeErr, kmswallet.ErrInvalidUserOperation)
+	assert.ErrorContains(t, tooLargeErr, "verificationGasLimit")
+	assert.ErrorIs(t, negativeErr, kmswallet.ErrInvalidUserOperation)
+	assert.ErrorContains(t, negativeErr, "maxFeePerGas -1 does not fit uint128")
+	assert.ErrorIs(t, paymasterErr, kmswallet.ErrInvalidUserOperation)
+	assert.ErrorIs(t, nonceErr, kmswallet.ErrInvalidUserOperation)
+	assert.ErrorIs(t, feeErr, kmswallet.ErrInvalidUserOperation)
+	assert.ErrorContains(t, feeErr, "maxFeePerGas")
+	assert.ErrorIs(t, chainIdErr, kmswallet.ErrInvalidUserOperation)
+}
@@ -0,0 +1,140 @@
+package kmswallet
+
+import (
+	"context"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"math/big"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// RecoverAddress recovers the address that signed hash. The signature can be the 65 bytes [R || S || V] form with
+// V as 0/1 or 27/28, or the 64 bytes EIP-2098 compact form. Signatures with a high S value are rejected.
+func RecoverAddress(hash []byte, signature []byte) (common.Address, error) {
+	if len(hash) != common.HashLength {
+		return common.Address{}, fmt.Errorf("hash is required to be exactly %d bytes (%d)", common.HashLength, len(hash))
+	}
+
+	normalized, err := normalizeSignature(signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	publicKey, err := crypto.SigToPub(hash, normalized)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// VerifyMessageSignature reports whether signature is address's signature of message with the
+// "\x19Ethereum Signed Message:\n" prefix, as produced by SignMessage.
+func VerifyMessageSignature(address common.Address, message []byte, signature []byte) (bool, error) {
+	return verifyHashSignature(address, toEthSignedMessageHash(message), signature)
+}
+
+// VerifyTypedDataSignature reports whether signature is address's signature of the EIP-712 hash of typedData.
+func VerifyTypedDataSignature(address common.Address, typedData apitypes.TypedData, signature []byte) (bool, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return false, err
+	}
+
+	return verifyHashSignature(address, hash, signature)
+}
+
+// VerifyWithKMS asks KMS whether signature is a valid signature of hash by the key, as a second opinion that does
+// not depend on local public key recovery. KMS only checks R and S, so the signature also has to recover, with its
+// V, to the address of the key.
+func (c *provider) VerifyWithKMS(ctx context.Context, keyId string, hash []byte, signature []byte) (bool, error) {
+	normalized, err := normalizeSignature(signature)
+	if err != nil {
+		return false, err
+	}
+
+	der, err := asn1.Marshal(struct {
+		R *big.Int
+		S *big.Int
+	}{
+		R: new(big.Int).SetBytes(normalized[:32]),
+		S: new(big.Int).SetBytes(normalized[32:64]),
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	output, err := c.client.Verify(ctx, &kms.VerifyInput{
+		KeyId:            aws.String(keyId),
+		Message:          hash,
+		MessageType:      types.MessageTypeDigest,
+		Signature:        der,
+		SigningAlgorithm: types.SigningAlgorithmSpecEcdsaSha256,
+	})
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "KMSInvalidSignatureException" {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("can not verify signature with KMS for keyId: %s, err: %+v", keyId, err)
+	}
+
+	if !output.SignatureValid {
+		return false, nil
+	}
+
+	publicKey, err := c.getPublicKey(ctx, keyId)
+	if err != nil {
+		return false, err
+	}
+
+	return verifyHashSignature(crypto.PubkeyToAddress(*publicKey), hash, signature)
+}
+
+func verifyHashSignature(address common.Address, hash []byte, signature []byte) (bool, error) {
+	recovered, err := RecoverAddress(hash, signature)
+	if err != nil {
+		return false, err
+	}
+
+	return recovered == address, nil
+}
+
+// normalizeSignature converts a 65 bytes signature with V as 0/1 or 27/28, or a 64 bytes EIP-2098 compact signature,
+// to the 65 bytes form with V as the recovery id.
+func normalizeSignature(signature []byte) ([]byte, error) {
+	normalized := make([]byte, crypto.SignatureLength)
+	switch len(signature) {
+	case crypto.SignatureLength:
+		copy(normalized, signature)
+		if normalized[64] >= 27 {
+			normalized[64] -= 27
+		}
+	case crypto.SignatureLength - 1:
+		copy(normalized, signature[:64])
+		normalized[64] = normalized[32] >> 7
+		normalized[32] &= 0x7f
+	default:
+		return nil, fmt.Errorf("%w: length is required to be 64 or 65 bytes (%d)", ErrInvalidSignature, len(signature))
+	}
+
+	r := new(big.Int).SetBytes(normalized[:32])
+	s := new(big.Int).SetBytes(normalized[32:64])
+	if !crypto.ValidateSignatureValues(normalized[64], r, s, true) {
+		return nil, fmt.Errorf("%w: signature values are out of range", ErrInvalidSignature)
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,189 @@
+package kmswallet_test
+
+import (
+	"context"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"math/big"
+	"testing"
+)
+
+func newTestTypedData() apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Mail": {
+				{Name: "from", Type: "address"},
+				{Name: "contents", Type: "string"},
+			},
+		},
+		PrimaryType: "Mail",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "Ether Mail",
+			Version:           "1",
+			ChainId:           math.NewHexOrDecimal256(1),
+			VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+		},
+		Message: apitypes.TypedDataMessage{
+			"from":     "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+			"contents": "Hello, Bob!",
+		},
+	}
+}
+
+func toCompact(signature []byte) []byte {
+	compact := append([]byte(nil), signature[:64]...)
+	if signature[64] == 1 || signature[64] == 28 {
+		compact[32] |= 0x80
+	}
+
+	return compact
+}
+
+func TestRecoverAddress_Should_Accept_All_Signature_Forms(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	hash := crypto.Keccak256([]byte("payload"))
+	signature, err := provider.SignHash(context.Background(), keyId, hash)
+	assert.NoError(t, err)
+
+	zeroOne := append([]byte(nil), signature...)
+	zeroOne[64] -= 27
+
+	for name, form := range map[string][]byte{"27/28": signature, "0/1": zeroOne, "compact": toCompact(signature)} {
+		// when
+		address, err := kmswallet.RecoverAddress(hash, form)
+
+		// then
+		assert.NoError(t, err, name)
+		assert.Equal(t, client.Address(keyId), address, name)
+	}
+}
+
+func TestRecoverAddress_Should_Reject_High_S_Signature(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	hash := crypto.Keccak256([]byte("payload"))
+	signature, err := provider.SignHash(context.Background(), keyId, hash)
+	assert.NoError(t, err)
+
+	s := new(big.Int).SetBytes(signature[32:64])
+	new(big.Int).Sub(crypto.S256().Params().N, s).FillBytes(signature[32:64])
+	signature[64] ^= 1
+
+	// when
+	_, err = kmswallet.RecoverAddress(hash, signature)
+
+	// then
+	assert.ErrorIs(t, err, kmswallet.ErrInvalidSignature)
+}
+
+func TestRecoverAddress_Should_Reject_Invalid_Length(t *testing.T) {
+	// when
+	_, err := kmswallet.RecoverAddress(crypto.Keccak256(nil), make([]byte, 63))
+
+	// then
+	assert.ErrorIs(t, err, kmswallet.ErrInvalidSignature)
+}
+
+func TestVerifyMessageSignature(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	message := []byte("Hello World!")
+	signature, err := provider.SignMessage(context.Background(), keyId, message)
+	assert.NoError(t, err)
+
+	// when
+	valid, err := kmswallet.VerifyMessageSignature(client.Address(keyId), message, signature)
+	validCompact, errCompact := kmswallet.VerifyMessageSignature(client.Address(keyId), message, toCompact(signature))
+	tampered, errTampered := kmswallet.VerifyMessageSignature(client.Address(keyId), []byte("Hello World?"), signature)
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	assert.NoError(t, errCompact)
+	assert.True(t, validCompact)
+	assert.NoError(t, errTampered)
+	assert.False(t, tampered)
+}
+
+func TestVerifyTypedDataSignature(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	typedData := newTestTypedData()
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	assert.NoError(t, err)
+	signature, err := provider.SignHash(context.Background(), keyId, hash)
+	assert.NoError(t, err)
+
+	// when
+	valid, err := kmswallet.VerifyTypedDataSignature(client.Address(keyId), typedData, signature)
+	otherSigner, errOther := kmswallet.VerifyTypedDataSignature(common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"), typedData, signature)
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	assert.NoError(t, errOther)
+	assert.False(t, otherSigner)
+}
+
+func TestVerifyWithKMS(t *testing.T) {
+	// given
+	client, keyId := newTestKMSClient(t)
+	provider := kmswallet.NewProvider(client, nil)
+	hash := crypto.Keccak256([]byte("payload"))
+	signature, err := provider.SignHash(context.Background(), keyId, hash)
+	assert.NoError(t, err)
+
+	// when
+	valid, err := provider.VerifyWithKMS(context.Background(), keyId, hash, signature)
+	invalid, errInvalid := provider.VerifyWithKMS(context.Background(), keyId, crypto.Keccak256([]byte("other")), signature)
+
+	flipped := append([]byte(nil), signature...)
+	flipped[64] = 55 - flipped[64]
+	flippedValid, errFlipped := provider.VerifyWithKMS(context.Background(), keyId, hash, flipped)
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	assert.NoError(t, errInvalid)
+	assert.False(t, invalid)
+	assert.NoError(t, errFlipped)
+	assert.False(t, flippedValid)
+}
+
+func TestVerifyWithKMS_When_KMS_Rejects_Signature(t *testing.T) {
+	// given
+	mockClient := &mockKMSClient{}
+	provider := kmswallet.NewProvider(mockClient, nil)
+	signingClient, signingKeyId := newTestKMSClient(t)
+	signature, _ := kmswallet.NewProvider(signingClient, nil).SignHash(context.Background(), signingKeyId, crypto.Keccak256(nil))
+
+	mockClient.On("Verify", mock.Anything, mock.Anything, mock.Anything).Return((*kms.VerifyOutput)(nil), &smithy.GenericAPIError{
+		Code: "KMSInvalidSignatureException",
+	})
+
+	// when
+	valid, err := provider.VerifyWithKMS(context.Background(), "keyId", crypto.Keccak256(nil), signature)
+
+	// then
+	assert.NoError(t, err)
+	assert.False(t, valid)
+	mockClient.AssertNumberOfCalls(t, "Verify", 1)
+}
@@ -0,0 +1,145 @@
+package kmswallet
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// WalletDetails describes the KMS key of a wallet. Address is empty when the key is not enabled and its public key
+// can not be read.
+type WalletDetails struct {
+	Address      string
+	KeyId        string
+	Arn          string
+	Aliases      []string
+	KeyState     types.KeyState
+	Description  string
+	CreationDate *time.Time
+	DeletionDate *time.Time
+}
+
+// GetWalletDetails returns the key state, aliases and address of the wallet.
+func (c *provider) GetWalletDetails(ctx context.Context, keyId string) (WalletDetails, error) {
+	output, err := c.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: &keyId})
+	if err != nil {
+		return WalletDetails{}, err
+	}
+
+	aliases, err := c.listAliases(ctx, output.KeyMetadata.KeyId)
+	if err != nil {
+		return WalletDetails{}, err
+	}
+
+	return c.walletDetails(ctx, output.KeyMetadata, aliases[*output.KeyMetadata.KeyId])
+}
+
+// ListWallets returns the details of every secp256k1 signing key of the account and region. Keys the caller is not
+// allowed to describe, or whose public key it is not allowed to read, are logged and skipped.
+func (c *provider) ListWallets(ctx context.Context) ([]WalletDetails, error) {
+	aliases, err := c.listAliases(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallets []WalletDetails
+	input := &kms.ListKeysInput{}
+	for {
+		output, err := c.client.ListKeys(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range output.Keys {
+			described, err := c.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: entry.KeyId})
+			if isAccessDenied(err) {
+				c.logger.WarnContext(ctx, "skipped key that can not be described", slog.String("keyId", aws.ToString(entry.KeyId)), slog.Any("err", err))
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			metadata := described.KeyMetadata
+			if metadata.KeySpec != types.KeySpecEccSecgP256k1 || metadata.KeyUsage != types.KeyUsageTypeSignVerify {
+				continue
+			}
+
+			wallet, err := c.walletDetails(ctx, metadata, aliases[aws.ToString(metadata.KeyId)])
+			if isAccessDenied(err) {
+				c.logger.WarnContext(ctx, "skipped key whose public key can not be read", slog.String("keyId", aws.ToString(metadata.KeyId)), slog.Any("err", err))
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			wallets = append(wallets, wallet)
+		}
+
+		if !output.Truncated {
+			return wallets, nil
+		}
+
+		input.Marker = output.NextMarker
+	}
+}
+
+func (c *provider) walletDetails(ctx context.Context, metadata *types.KeyMetadata, aliases []string) (WalletDetails, error) {
+	details := WalletDetails{
+		KeyId:        aws.ToString(metadata.KeyId),
+		Arn:          aws.ToString(metadata.Arn),
+		Aliases:      aliases,
+		KeyState:     metadata.KeyState,
+		Description:  aws.ToString(metadata.Description),
+		CreationDate: metadata.CreationDate,
+		DeletionDate: metadata.DeletionDate,
+	}
+
+	wallet, err := c.GetWallet(ctx, details.KeyId)
+	if err != nil && metadata.KeyState == types.KeyStateEnabled {
+		return details, err
+	}
+
+	details.Address = wallet.Address
+	return details, nil
+}
+
+// listAliases returns the alias names, without the "alias/" prefix, of keyId or of every key when keyId is nil.
+func (c *provider) listAliases(ctx context.Context, keyId *string) (map[string][]string, error) {
+	aliases := map[string][]string{}
+	input := &kms.ListAliasesInput{KeyId: keyId}
+	for {
+		output, err := c.client.ListAliases(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, alias := range output.Aliases {
+			if alias.TargetKeyId == nil {
+				continue
+			}
+
+			aliases[*alias.TargetKeyId] = append(aliases[*alias.TargetKeyId], strings.TrimPrefix(aws.ToString(alias.AliasName), "alias/"))
+		}
+
+		if !output.Truncated {
+			return aliases, nil
+		}
+
+		input.Marker = output.NextMarker
+	}
+}
+
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException"
+}
@@ -0,0 +1,267 @@
+// Package web3signer serves the Web3Signer eth1 HTTP API with KMS wallets, so clients of Web3Signer can sign with
+// KMS keys unchanged.
+package web3signer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/internal/ethrpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+var errUnknownIdentifier = errors.New("unknown identifier")
+
+type wallet struct {
+	keyId     string
+	address   common.Address
+	publicKey hexutil.Bytes
+}
+
+// Server implements the Web3Signer eth1 endpoints:
+//
+//	GET  /upcheck
+//	GET  /api/v1/eth1/publicKeys
+//	POST /api/v1/eth1/sign/{identifier}
+//	POST /  (JSON-RPC: eth_accounts, eth_sign and eth_signTransaction)
+//
+// Identifiers are public keys, addresses or aliases of the configured wallets. Like Web3Signer, the server only signs
+// with the wallets it was started with; aliases are resolved once, in NewServer.
+type Server struct {
+	provider kmswallet.Provider
+	chainId  *big.Int
+	logger   *slog.Logger
+	wallets  []wallet
+	aliases  map[string]string
+	mux      *http.ServeMux
+}
+
+type Option func(s *Server)
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// NewServer resolves the wallets of keys, which are keyIds or aliases with the "alias/" prefix.
+func NewServer(ctx context.Context, provider kmswallet.Provider, chainId *big.Int, keys []string, opts ...Option) (*Server, error) {
+	s := &Server{
+		provider: provider,
+		chainId:  chainId,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		aliases:  map[string]string{},
+		mux:      http.NewServeMux(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	for _, key := range keys {
+		keyId := key
+		if alias, ok := strings.CutPrefix(key, "alias/"); ok {
+			var err error
+			if keyId, err = provider.GetKeyIdByAlias(ctx, alias); err != nil {
+				return nil, err
+			}
+
+			s.aliases[alias] = keyId
+		}
+
+		w, err := s.wallet(ctx, keyId)
+		if err != nil {
+			return nil, err
+		}
+
+		s.wallets = append(s.wallets, w)
+	}
+
+	s.mux.HandleFunc("GET /upcheck", s.upcheck)
+	s.mux.HandleFunc("GET /api/v1/eth1/publicKeys", s.publicKeys)
+	s.mux.HandleFunc("POST /api/v1/eth1/sign/{identifier}", s.sign)
+	s.mux.HandleFunc("POST /{$}", func(w http.ResponseWriter, r *http.Request) {
+		ethrpc.Serve(w, r, s.handleRPC)
+	})
+
+	return s, nil
+}
+
+// ServeHTTP serves the Web3Signer API. Signing requests need a JSON Content-Type, but the Host and Origin of requests are
+// not checked; wrap it in a Host allowlist and CORS policy, like node.NewHTTPHandlerStack of go-ethereum, when
+// mounting it in your own server.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) upcheck(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("OK"))
+}
+
+func (s *Server) publicKeys(w http.ResponseWriter, _ *http.Request) {
+	publicKeys := make([]hexutil.Bytes, len(s.wallets))
+	for i, w := range s.wallets {
+		publicKeys[i] = w.publicKey
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(publicKeys)
+}
+
+// sign signs the keccak hash of the data in the request body and responds with the hex encoded [R || S || V]
+// signature, V being 27 or 28. Like the JSON-RPC endpoint, it requires a JSON Content-Type.
+func (s *Server) sign(w http.ResponseWriter, r *http.Request) {
+	if !ethrpc.CheckContentType(w, r) {
+		return
+	}
+
+	var request struct {
+		Data *hexutil.Bytes `json:"data"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Data == nil {
+		http.Error(w, "data is required to be hex encoded", http.StatusBadRequest)
+		return
+	}
+
+	keyId, err := s.resolve(r.Context(), r.PathValue("identifier"))
+	if errors.Is(err, errUnknownIdentifier) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var signature []byte
+	if err == nil {
+		signature, err = s.provider.SignHash(r.Context(), keyId, crypto.Keccak256(*request.Data))
+	}
+
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "can not sign data", slog.String("identifier", r.PathValue("identifier")), slog.Any("err", err))
+		http.Error(w, "signing failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(hexutil.Encode(signature)))
+}
+
+func (s *Server) handleRPC(ctx context.Context, request *ethrpc.Request) (interface{}, error) {
+	switch request.Method {
+	case "eth_accounts":
+		addresses := make([]common.Address, len(s.wallets))
+		for i, w := range s.wallets {
+			addresses[i] = w.address
+		}
+
+		return addresses, nil
+	case "eth_sign":
+		var address common.Address
+		var data hexutil.Bytes
+		if err := ethrpc.ParseParams(request, &address, &data); err != nil {
+			return nil, err
+		}
+
+		keyId, err := s.resolve(ctx, address.Hex())
+		if err != nil {
+			return nil, err
+		}
+
+		signature, err := s.provider.SignMessage(ctx, keyId, data)
+		return hexutil.Bytes(signature), err
+	case "eth_signTransaction":
+		var args ethrpc.TransactionArgs
+		if err := ethrpc.ParseParams(request, &args); err != nil {
+			return nil, err
+		}
+
+		tx, err := args.ToTransaction(s.chainId)
+		if err != nil {
+			return nil, err
+		}
+
+		keyId, err := s.resolve(ctx, args.From.Hex())
+		if err != nil {
+			return nil, err
+		}
+
+		signedTx, err := s.provider.SignTransactionForChain(ctx, keyId, s.chainId, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := signedTx.MarshalBinary()
+		return hexutil.Bytes(raw), err
+	default:
+		return nil, ethrpc.MethodNotFound(request.Method)
+	}
+}
+
+// resolve returns the keyId of the configured wallet with a public key, address or alias identifier.
+func (s *Server) resolve(ctx context.Context, identifier string) (string, error) {
+	address, isAddress, err := identifierAddress(identifier)
+	if err != nil {
+		return "", err
+	}
+
+	if isAddress {
+		for _, w := range s.wallets {
+			if w.address == address {
+				return w.keyId, nil
+			}
+		}
+	} else if keyId, ok := s.aliases[identifier]; ok {
+		return keyId, nil
+	}
+
+	s.logger.DebugContext(ctx, "identifier is not a configured wallet", slog.String("identifier", identifier))
+	return "", fmt.Errorf("%w: %s", errUnknownIdentifier, identifier)
+}
+
+func (s *Server) wallet(ctx context.Context, keyId string) (wallet, error) {
+	publicKey, err := s.provider.GetPublicKey(ctx, keyId)
+	if err != nil {
+		return wallet{}, err
+	}
+
+	return wallet{
+		keyId:     keyId,
+		address:   crypto.PubkeyToAddress(*publicKey),
+		publicKey: crypto.FromECDSAPub(publicKey)[1:],
+	}, nil
+}
+
+// identifierAddress returns the address of an address or public key identifier.
+func identifierAddress(identifier string) (common.Address, bool, error) {
+	if !strings.HasPrefix(identifier, "0x") {
+		return common.Address{}, false, nil
+	}
+
+	decoded, err := hexutil.Decode(identifier)
+	if err != nil {
+		return common.Address{}, false, fmt.Errorf("%w: %s", errUnknownIdentifier, identifier)
+	}
+
+	switch len(decoded) {
+	case common.AddressLength:
+		return common.BytesToAddress(decoded), true, nil
+	case 64, 65:
+		publicKey, err := crypto.UnmarshalPubkey(append([]byte{0x04}, decoded[len(decoded)-64:]...))
+		if err != nil {
+			return common.Address{}, false, fmt.Errorf("%w: %s", errUnknownIdentifier, identifier)
+		}
+
+		return crypto.PubkeyToAddress(*publicKey), true, nil
+	default:
+		return common.Address{}, false, fmt.Errorf("%w: %s", errUnknownIdentifier, identifier)
+	}
+}
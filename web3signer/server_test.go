@@ -0,0 +1,216 @@
+package web3signer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	kmswallet "github.com/aliarbak/go-ethereum-aws-kms-wallet-provider"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/kmstest"
+	"github.com/aliarbak/go-ethereum-aws-kms-wallet-provider/web3signer"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testChainId = big.NewInt(17000)
+
+func newTestServer(t *testing.T) (*kmstest.Client, string, *httptest.Server) {
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	return client, keyId, startServer(t, client, keyId)
+}
+
+func startServer(t *testing.T, client *kmstest.Client, keys ...string) *httptest.Server {
+	server, err := web3signer.NewServer(context.Background(), kmswallet.NewProvider(client, nil), testChainId, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+func sign(t *testing.T, httpServer *httptest.Server, identifier string, data []byte) (int, string) {
+	body, _ := json.Marshal(map[string]hexutil.Bytes{"data": data})
+	response, err := http.Post(httpServer.URL+"/api/v1/eth1/sign/"+identifier, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(response.Body)
+	return response.StatusCode, string(responseBody)
+}
+
+func TestPublicKeys(t *testing.T) {
+	// given
+	client, keyId, httpServer := newTestServer(t)
+
+	// when
+	response, err := http.Get(httpServer.URL + "/api/v1/eth1/publicKeys")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	var publicKeys []hexutil.Bytes
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&publicKeys))
+	assert.Len(t, publicKeys, 1)
+	assert.Len(t, publicKeys[0], 64)
+
+	publicKey, err := crypto.UnmarshalPubkey(append([]byte{0x04}, publicKeys[0]...))
+	assert.NoError(t, err)
+	assert.Equal(t, client.Address(keyId), crypto.PubkeyToAddress(*publicKey))
+}
+
+func TestSign_Should_Accept_Public_Key_Address_And_Alias_Identifiers(t *testing.T) {
+	// given
+	client := kmstest.NewClient()
+	keyId := client.AddKey(t)
+	aliasKeyId := client.AddKey(t)
+	client.AddAlias("treasury", aliasKeyId)
+	httpServer := startServer(t, client, keyId, "alias/treasury")
+
+	response, _ := http.Get(httpServer.URL + "/api/v1/eth1/publicKeys")
+	var publicKeys []hexutil.Bytes
+	_ = json.NewDecoder(response.Body).Decode(&publicKeys)
+
+	data := []byte("web3signer payload")
+	identifiers := map[string]common.Address{
+		publicKeys[0].String():           client.Address(keyId),
+		client.Address(keyId).Hex():      client.Address(keyId),
+		"treasury":                       client.Address(aliasKeyId),
+		client.Address(aliasKeyId).Hex(): client.Address(aliasKeyId),
+	}
+
+	for identifier, expected := range identifiers {
+		// when
+		status, body := sign(t, httpServer, identifier, data)
+
+		// then
+		assert.Equal(t, http.StatusOK, status, identifier)
+		signature, err := hexutil.Decode(body)
+		assert.NoError(t, err)
+		assert.Contains(t, []byte{27, 28}, signature[64])
+
+		signer, err := kmswallet.RecoverAddress(crypto.Keccak256(data), signature)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, signer, identifier)
+	}
+}
+
+func TestSign_When_Identifier_Is_Unknown(t *testing.T) {
+	// given
+	client, _, httpServer := newTestServer(t)
+	unconfiguredKeyId := client.AddKey(t)
+	client.AddAlias("treasury", unconfiguredKeyId)
+	client.AddAlias(client.Address(unconfiguredKeyId).Hex(), unconfiguredKeyId)
+
+	for _, identifier := range []string{"missing", "0x1234", "treasury", client.Address(unconfiguredKeyId).Hex()} {
+		// when
+		status, _ := sign(t, httpServer, identifier, []byte("payload"))
+
+		// then
+		assert.Equal(t, http.StatusNotFound, status, identifier)
+	}
+}
+
+func TestSign_Should_Require_JSON_Content_Type(t *testing.T) {
+	// given
+	client, keyId, httpServer := newTestServer(t)
+	body, _ := json.Marshal(map[string]hexutil.Bytes{"data": []byte("hello")})
+	rpcBody := `{"jsonrpc":"2.0","id":1,"method":"eth_sign","params":["` + client.Address(keyId).Hex() + `","0x01"]}`
+
+	// when
+	signResponse, signErr := http.Post(httpServer.URL+"/api/v1/eth1/sign/"+client.Address(keyId).Hex(), "text/plain", bytes.NewReader(body))
+	rpcResponse, rpcErr := http.Post(httpServer.URL, "text/plain", strings.NewReader(rpcBody))
+	if signErr != nil || rpcErr != nil {
+		t.Fatal(signErr, rpcErr)
+	}
+
+	defer signResponse.Body.Close()
+	defer rpcResponse.Body.Close()
+
+	// then
+	assert.Equal(t, http.StatusUnsupportedMediaType, signResponse.StatusCode)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rpcResponse.StatusCode)
+	assert.Equal(t, 0, client.SignCalls())
+}
+
+func TestJSONRPC(t *testing.T) {
+	// given
+	client, keyId, httpServer := newTestServer(t)
+	rpcClient, err := rpc.Dial(httpServer.URL)
+	assert.NoError(t, err)
+	address := client.Address(keyId)
+	to := common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582")
+
+	// when
+	var addresses []common.Address
+	accountsErr := rpcClient.Call(&addresses, "eth_accounts")
+
+	var signature hexutil.Bytes
+	signErr := rpcClient.Call(&signature, "eth_sign", address, hexutil.Bytes("hello"))
+
+	var rawTx hexutil.Bytes
+	signTxErr := rpcClient.Call(&rawTx, "eth_signTransaction", map[string]interface{}{
+		"from":                 address,
+		"to":                   to,
+		"gas":                  hexutil.Uint64(21000),
+		"maxFeePerGas":         (*hexutil.Big)(big.NewInt(30e9)),
+		"maxPriorityFeePerGas": (*hexutil.Big)(big.NewInt(1e9)),
+		"value":                (*hexutil.Big)(big.NewInt(1)),
+		"nonce":                hexutil.Uint64(4),
+	})
+
+	var ignored interface{}
+	unknownErr := rpcClient.Call(&ignored, "eth_blockNumber")
+
+	// then
+	assert.NoError(t, accountsErr)
+	assert.Equal(t, []common.Address{address}, addresses)
+
+	assert.NoError(t, signErr)
+	signer, err := kmswallet.RecoverAddress(accounts.TextHash([]byte("hello")), signature)
+	assert.NoError(t, err)
+	assert.Equal(t, address, signer)
+
+	assert.NoError(t, signTxErr)
+	tx := new(types.Transaction)
+	assert.NoError(t, tx.UnmarshalBinary(rawTx))
+	sender, err := types.Sender(types.LatestSignerForChainID(testChainId), tx)
+	assert.NoError(t, err)
+	assert.Equal(t, address, sender)
+	assert.Equal(t, uint64(4), tx.Nonce())
+	assert.Equal(t, testChainId, tx.ChainId())
+
+	assert.ErrorContains(t, unknownErr, "the method eth_blockNumber does not exist/is not available")
+}
+
+func TestJSONRPC_SignTransaction_When_Nonce_Is_Missing(t *testing.T) {
+	// given
+	client, keyId, httpServer := newTestServer(t)
+	rpcClient, _ := rpc.Dial(httpServer.URL)
+
+	// when
+	var rawTx hexutil.Bytes
+	err := rpcClient.Call(&rawTx, "eth_signTransaction", map[string]interface{}{
+		"from":     client.Address(keyId),
+		"gas":      hexutil.Uint64(21000),
+		"gasPrice": (*hexutil.Big)(big.NewInt(1e9)),
+	})
+
+	// then
+	assert.ErrorContains(t, err, "nonce is required")
+}